@@ -11,21 +11,16 @@ import (
 	"github.com/WICG/webpackage/go/integrityblock"
 	"github.com/WICG/webpackage/go/integrityblock/webbundleid"
 	"github.com/WICG/webpackage/go/internal/cbor"
-	"github.com/WICG/webpackage/go/internal/signingalgorithm"
 )
 
-func writeOutput(bundleFile io.ReadSeeker, integrityBlockBytes []byte, originalIntegrityBlockOffset int64, signedBundleFile *os.File) error {
-	signedBundleFile.Write(integrityBlockBytes)
-
+func writeOutput(bundleFile io.ReadSeeker, integrityBlock *integrityblock.IntegrityBlock, originalIntegrityBlockOffset int64, signedBundleFile *os.File) error {
 	// Move the file pointer to the start of the web bundle bytes.
-	bundleFile.Seek(originalIntegrityBlockOffset, io.SeekStart)
-
-	// io.Copy() will do chunked read/write under the hood
-	_, err := io.Copy(signedBundleFile, bundleFile)
-	if err != nil {
+	if _, err := bundleFile.Seek(originalIntegrityBlockOffset, io.SeekStart); err != nil {
 		return err
 	}
-	return nil
+
+	_, err := integrityblock.WriteSignedBundle(signedBundleFile, integrityBlock, bundleFile)
+	return err
 }
 
 func readPublicEd25519KeyFromFile(path string) (ed25519.PublicKey, error) {
@@ -33,26 +28,15 @@ func readPublicEd25519KeyFromFile(path string) (ed25519.PublicKey, error) {
 	if err != nil {
 		return nil, errors.New("SignIntegrityBlock: Unable to read the public key.")
 	}
-	pubKey, err := signingalgorithm.ParsePublicKey(pubkeytext)
-
-	ed25519pubKey, ok := pubKey.(ed25519.PublicKey)
-	if !ok {
-		return nil, errors.New("SignIntegrityBlock: Public key is not Ed25519 type.")
-	}
-	return ed25519pubKey, nil
+	return integrityblock.ParseEd25519PublicKeyFromPEM(pubkeytext)
 }
 
 func readAndParseEd25519PrivateKey(path string) (ed25519.PrivateKey, error) {
-	privKey, err := readPrivateKeyFromFile(path)
+	privkeytext, err := ioutil.ReadFile(path)
 	if err != nil {
 		return nil, errors.New("SignIntegrityBlock: Unable to read the private key.")
 	}
-
-	ed25519privKey, ok := privKey.(ed25519.PrivateKey)
-	if !ok {
-		return nil, errors.New("SignIntegrityBlock: Private key is not Ed25519 type.")
-	}
-	return ed25519privKey, nil
+	return integrityblock.ParseEd25519PrivateKeyFromPEM(privkeytext)
 }
 
 func DumpWebBundleIdFromPrivateKey() error {
@@ -155,5 +139,5 @@ func SignWithIntegrityBlock(bundleFileIn, bundleFileOut *os.File, signingStrateg
 	webBundleId := webbundleid.GetWebBundleId(ed25519publicKey)
 	fmt.Println("Web Bundle ID: " + webBundleId)
 
-	return writeOutput(bundleFileIn, integrityBlockBytes, offset, bundleFileOut)
+	return writeOutput(bundleFileIn, integrityBlock, offset, bundleFileOut)
 }
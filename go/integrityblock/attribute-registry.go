@@ -0,0 +1,107 @@
+package integrityblock
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// AttributeInfo describes a signature attribute known to this package.
+type AttributeInfo struct {
+	// ValueSize is the exact size of the attribute value in bytes, or 0 if the size is not fixed.
+	ValueSize int
+	// Algorithm is the signing algorithm identified by a public key attribute, e.g. "Ed25519". It is empty
+	// for the attributes which are not public keys, which must leave the fields below unset as well.
+	Algorithm string
+	// MinSignatureSize and MaxSignatureSize are the limits of the signature size of the signing algorithm in
+	// bytes. They are equal for algorithms with fixed size signatures.
+	MinSignatureSize, MaxSignatureSize int
+	// Verify verifies an integrity signature of the signing algorithm like `VerifyIntegritySignature`.
+	Verify func(ib *IntegrityBlock, is *IntegritySignature, webBundleHash []byte) (bool, error)
+}
+
+// attributeRegistry maps the names of the signature attributes known to this package to their descriptions.
+// The public key attributes in it identify the supported signing algorithms. `ParseIntegrityBlockStrict`
+// rejects the attributes missing from it. Use `RegisterAttribute` to extend it.
+var attributeRegistry = map[string]AttributeInfo{
+	Ed25519publicKeyAttributeName: {
+		ValueSize:        Ed25519PublicKeySize,
+		Algorithm:        "Ed25519",
+		MinSignatureSize: Ed25519SignatureSize,
+		MaxSignatureSize: Ed25519SignatureSize,
+		Verify:           VerifyIntegritySignature,
+	},
+	// ASN.1 DER encoded ECDSA signatures vary in size.
+	EcdsaP256SHA256PublicKeyAttributeName: {
+		ValueSize:        ecdsaP256CompressedPublicKeySize,
+		Algorithm:        "ECDSA P-256 SHA-256",
+		MinSignatureSize: 8,
+		MaxSignatureSize: 72,
+		Verify:           VerifyIntegritySignatureECDSA,
+	},
+	DateAttributeName: {},
+}
+
+// LookupAttribute returns the description of the signature attribute if it is known to this package.
+func LookupAttribute(name string) (AttributeInfo, bool) {
+	info, ok := attributeRegistry[name]
+	return info, ok
+}
+
+// PublicKeyAttributeNames returns the attribute names of the public keys of the supported signing algorithms
+// in sorted order.
+func PublicKeyAttributeNames() []string {
+	var names []string
+	for name, info := range attributeRegistry {
+		if info.Algorithm != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RegisterAttribute adds a new signature attribute to the attributes known to this package, so that third parties
+// can introduce attributes without forking the package. An attribute with an `Algorithm` is a public key
+// attribute adding a signing algorithm, so it must also have the signature size limits and a `Verify` function.
+// It fails if the attribute is already registered. It is not safe for concurrent use and is meant to be called
+// during initialization, e.g. from an init function.
+func RegisterAttribute(name string, info AttributeInfo) error {
+	if name == "" {
+		return errors.New("integrityblock: Attribute name cannot be empty.")
+	}
+	if info.ValueSize < 0 {
+		return fmt.Errorf("integrityblock: Value size of the %q attribute cannot be negative.", name)
+	}
+	if info.Algorithm == "" {
+		if info.MinSignatureSize != 0 || info.MaxSignatureSize != 0 || info.Verify != nil {
+			return fmt.Errorf("integrityblock: Attribute %q without an algorithm cannot have signature sizes or a Verify function.", name)
+		}
+	} else {
+		if info.Verify == nil {
+			return fmt.Errorf("integrityblock: Public key attribute %q needs a Verify function.", name)
+		}
+		if info.MinSignatureSize < 0 || info.MaxSignatureSize <= 0 || info.MinSignatureSize > info.MaxSignatureSize {
+			return fmt.Errorf("integrityblock: Public key attribute %q has invalid signature size limits %d to %d.", name, info.MinSignatureSize, info.MaxSignatureSize)
+		}
+	}
+	if _, exists := attributeRegistry[name]; exists {
+		return fmt.Errorf("integrityblock: Attribute %q is already registered.", name)
+	}
+
+	attributeRegistry[name] = info
+	return nil
+}
+
+// checkRegisteredAttribute fails if the signature attribute is not known to this package or if its value
+// doesn't have the registered size.
+func checkRegisteredAttribute(name string, value []byte) error {
+	info, ok := attributeRegistry[name]
+	if !ok {
+		return fmt.Errorf("Unknown signature attribute %q.", name)
+	}
+	if info.ValueSize != 0 && len(value) != info.ValueSize {
+		return fmt.Errorf("Signature attribute %q should be %d bytes, got %d bytes.", name, info.ValueSize, len(value))
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package integrityblock
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestAttributeRegistryBuiltins(t *testing.T) {
+	names := PublicKeyAttributeNames()
+	if len(names) != 2 {
+		t.Errorf("integrityblock: got public key attributes: %v, want the Ed25519 and ECDSA ones", names)
+	}
+	for _, name := range names {
+		info, ok := LookupAttribute(name)
+		if !ok || info.Algorithm == "" || info.Verify == nil || info.MaxSignatureSize == 0 {
+			t.Errorf("integrityblock: public key attribute %q should be registered with an algorithm, got: %+v", name, info)
+		}
+	}
+	if info, _ := LookupAttribute(Ed25519publicKeyAttributeName); info.ValueSize != Ed25519PublicKeySize {
+		t.Errorf("integrityblock: got Ed25519 public key size: %d\nwant: %d", info.ValueSize, Ed25519PublicKeySize)
+	}
+	if _, ok := LookupAttribute("unknown"); ok {
+		t.Error("Unknown attribute should not be registered.")
+	}
+}
+
+func TestRegisterAttribute(t *testing.T) {
+	const name = "mldsa44PublicKey"
+	defer delete(attributeRegistry, name)
+
+	signature := bytes.Repeat([]byte{0x01}, 2420)
+	integrityBlock := generateEmptyIntegrityBlock()
+	integrityBlock.addNewSignatureToIntegrityBlock(SignatureAttributesMap{name: make([]byte, 1312)}, signature)
+	integrityBlockBytes, err := integrityBlock.CborBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseIntegrityBlockStrict(bytes.NewReader(integrityBlockBytes)); err == nil || !strings.Contains(err.Error(), "Unknown signature attribute") {
+		t.Errorf("integrityblock: got err: %v, want an unknown attribute error", err)
+	}
+
+	verify := func(ib *IntegrityBlock, is *IntegritySignature, webBundleHash []byte) (bool, error) {
+		return bytes.Equal(is.Signature, signature), nil
+	}
+	info := AttributeInfo{ValueSize: 1312, Algorithm: "ML-DSA-44", MinSignatureSize: 2420, MaxSignatureSize: 2420, Verify: verify}
+	if err := RegisterAttribute(name, info); err != nil {
+		t.Fatalf("RegisterAttribute. err: %v", err)
+	}
+	if !isPublicKeyAttributeName(name) {
+		t.Error("Attribute registered with an algorithm should be a public key attribute.")
+	}
+	if _, err := ParseIntegrityBlockStrict(bytes.NewReader(integrityBlockBytes)); err != nil {
+		t.Errorf("ParseIntegrityBlockStrict with a registered attribute. err: %v", err)
+	}
+	// The verifier uses the registered algorithm.
+	result, err := VerifyIntegrityBlock(integrityBlock, sha512Helper(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Valid {
+		t.Errorf("integrityblock: got result: %+v, want valid", result.Signatures)
+	}
+
+	integrityBlock.SignatureStack[0].Signature = []byte("signature")
+	if err := integrityBlock.Validate(); err == nil || !strings.Contains(err.Error(), "should be 2420 bytes") {
+		t.Errorf("integrityblock: got err: %v, want a signature size error", err)
+	}
+
+	integrityBlock.SignatureStack[0].SignatureAttributes[name] = make([]byte, 32)
+	integrityBlockBytes, err = integrityBlock.CborBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseIntegrityBlockStrict(bytes.NewReader(integrityBlockBytes)); err == nil || !strings.Contains(err.Error(), "should be 1312 bytes") {
+		t.Errorf("integrityblock: got err: %v, want a value size error", err)
+	}
+
+	for _, test := range []struct {
+		name string
+		info AttributeInfo
+	}{
+		{name, AttributeInfo{}},
+		{Ed25519publicKeyAttributeName, AttributeInfo{}},
+		{"", AttributeInfo{}},
+		{"negativeSize", AttributeInfo{ValueSize: -1}},
+		{"withoutVerify", AttributeInfo{Algorithm: "X", MinSignatureSize: 1, MaxSignatureSize: 1}},
+		{"withoutSignatureSizes", AttributeInfo{Algorithm: "X", Verify: verify}},
+		{"invertedSignatureSizes", AttributeInfo{Algorithm: "X", MinSignatureSize: 2, MaxSignatureSize: 1, Verify: verify}},
+		{"verifyWithoutAlgorithm", AttributeInfo{Verify: verify}},
+	} {
+		if err := RegisterAttribute(test.name, test.info); err == nil {
+			t.Errorf("RegisterAttribute(%q, %+v) should fail.", test.name, test.info)
+		}
+	}
+}
@@ -0,0 +1,16 @@
+package integrityblock
+
+// CreateDetachedSignature creates an integrity signature over the web bundle hash which is stored separately
+// from the web bundle, so that the web bundle itself stays unmodified. The signature covers an empty integrity
+// block, so at serve time it is applied by prepending it to a new integrity block with `AppendSignature` and
+// writing the signed web bundle with `WriteSignedBundle`.
+func CreateDetachedSignature(webBundleHash []byte, signer Signer) (*IntegritySignature, error) {
+	return SignIntegrityBlock(generateEmptyIntegrityBlock(), signer, webBundleHash)
+}
+
+// VerifyDetachedSignature verifies an integrity signature created with `CreateDetachedSignature` against the
+// web bundle hash. Like `VerifyIntegritySignature`, the returned boolean is false with a nil error if the
+// signature is well-formed but doesn't verify.
+func VerifyDetachedSignature(is *IntegritySignature, webBundleHash []byte) (bool, error) {
+	return verifyIntegritySignatureOfAnyAlgorithm(generateEmptyIntegrityBlock(), is, webBundleHash)
+}
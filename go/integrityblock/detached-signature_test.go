@@ -0,0 +1,58 @@
+package integrityblock
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetachedSignature(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal("Failed to generate test keys")
+	}
+	signer := NewParsedEd25519KeySigningStrategy(priv)
+	webBundleBytes, err := os.ReadFile("./testfile.wbn")
+	if err != nil {
+		t.Fatal("Failed to read the test file")
+	}
+	webBundleHash := sha512Helper(webBundleBytes)
+
+	detachedSignature, err := CreateDetachedSignature(webBundleHash, signer)
+	if err != nil {
+		t.Fatalf("CreateDetachedSignature. err: %v", err)
+	}
+
+	ok, err := VerifyDetachedSignature(detachedSignature, webBundleHash)
+	if err != nil || !ok {
+		t.Errorf("integrityblock: got: %v, %v\nwant: true, <nil>", ok, err)
+	}
+	if ok, err := VerifyDetachedSignature(detachedSignature, sha512Helper([]byte("other"))); err != nil || ok {
+		t.Errorf("integrityblock: got: %v, %v\nwant: false, <nil>", ok, err)
+	}
+
+	// Applying the detached signature at serve time produces the same signed web bundle as signing directly.
+	integrityBlock := generateEmptyIntegrityBlock()
+	if err := AppendSignature(integrityBlock, detachedSignature); err != nil {
+		t.Fatal(err)
+	}
+	var got bytes.Buffer
+	if _, err := WriteSignedBundle(&got, integrityBlock, bytes.NewReader(webBundleBytes)); err != nil {
+		t.Fatal(err)
+	}
+
+	outputPath := filepath.Join(t.TempDir(), "signed.wbn")
+	if err := SignBundleFile("./testfile.wbn", outputPath, signer); err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Bytes(), want) {
+		t.Error("Web bundle signed with the detached signature should equal the one signed directly.")
+	}
+}
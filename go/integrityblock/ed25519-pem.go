@@ -0,0 +1,66 @@
+package integrityblock
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	"github.com/WICG/webpackage/go/integrityblock/webbundleid"
+	"github.com/WICG/webpackage/go/internal/signingalgorithm"
+)
+
+// ParseEd25519PrivateKeyFromPEM parses an Ed25519 private key from PEM encoded PKCS#8, which is the format
+// `openssl genpkey -algorithm Ed25519` produces. If the PEM contains multiple blocks, the first block
+// containing a private key is used. Passphrase-encrypted keys are supported as well.
+func ParseEd25519PrivateKeyFromPEM(pemBytes []byte) (ed25519.PrivateKey, error) {
+	privKey, err := signingalgorithm.ParsePrivateKey(pemBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	ed25519privKey, ok := privKey.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.New("integrityblock: Private key is not Ed25519 type.")
+	}
+	return ed25519privKey, nil
+}
+
+// ParseEd25519PublicKeyFromPEM parses an Ed25519 public key from PEM encoded PKIX. If the PEM contains
+// multiple blocks, the first block containing a public key is used.
+func ParseEd25519PublicKeyFromPEM(pemBytes []byte) (ed25519.PublicKey, error) {
+	pubKey, err := signingalgorithm.ParsePublicKey(pemBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	ed25519pubKey, ok := pubKey.(ed25519.PublicKey)
+	if !ok {
+		return nil, errors.New("integrityblock: Public key is not Ed25519 type.")
+	}
+	return ed25519pubKey, nil
+}
+
+// GenerateEd25519KeyPair generates a new Ed25519 signing key. Besides the key pair, it returns the private key
+// as PEM encoded PKCS#8, which is ready to be saved and understood by `ParseEd25519PrivateKeyFromPEM`, and the
+// Web Bundle ID derived from the public key.
+func GenerateEd25519KeyPair() (pub ed25519.PublicKey, priv ed25519.PrivateKey, pemPrivate []byte, webBundleId webbundleid.WebBundleId, err error) {
+	pub, priv, err = ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, nil, webbundleid.WebBundleId{}, fmt.Errorf("integrityblock: Failed to generate Ed25519 key: %v", err)
+	}
+
+	derPrivate, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return nil, nil, nil, webbundleid.WebBundleId{}, fmt.Errorf("integrityblock: Failed to marshal Ed25519 private key: %v", err)
+	}
+	pemPrivate = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: derPrivate})
+
+	webBundleId, err = webbundleid.NewWebBundleId(pub)
+	if err != nil {
+		return nil, nil, nil, webbundleid.WebBundleId{}, err
+	}
+	return pub, priv, pemPrivate, webBundleId, nil
+}
@@ -0,0 +1,36 @@
+package integrityblock
+
+import (
+	"crypto/sha512"
+	"hash"
+	"io"
+)
+
+// HashingWriter wraps an io.Writer and computes the SHA-512 hash over everything written through it, so that
+// a web bundle generated on the fly is hashed while it is written out. It is meant for a single writer and
+// is not safe for concurrent use.
+type HashingWriter struct {
+	w io.Writer
+	h hash.Hash
+}
+
+// NewHashingWriter returns a HashingWriter writing into `w`.
+func NewHashingWriter(w io.Writer) *HashingWriter {
+	return &HashingWriter{w: w, h: sha512.New()}
+}
+
+// Write implements io.Writer and adds the bytes which were successfully written to the running hash.
+func (hw *HashingWriter) Write(p []byte) (int, error) {
+	n, err := hw.w.Write(p)
+	if n > 0 {
+		// hash.Hash never returns an error.
+		hw.h.Write(p[:n])
+	}
+	return n, err
+}
+
+// Sum returns the SHA-512 hash of the bytes written so far, which after writing the whole web bundle is
+// the web bundle hash to sign.
+func (hw *HashingWriter) Sum() []byte {
+	return hw.h.Sum(nil)
+}
@@ -0,0 +1,39 @@
+package integrityblock
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestHashingWriter(t *testing.T) {
+	webBundleBytes, err := os.ReadFile("./testfile.wbn")
+	if err != nil {
+		t.Fatal("Failed to read the test file")
+	}
+
+	var written bytes.Buffer
+	hashingWriter := NewHashingWriter(&written)
+	// Write in parts like a web bundle generated on the fly.
+	for _, part := range [][]byte{webBundleBytes[:10], webBundleBytes[10:100], webBundleBytes[100:]} {
+		if _, err := hashingWriter.Write(part); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if !bytes.Equal(written.Bytes(), webBundleBytes) {
+		t.Error("HashingWriter should pass the written bytes through unchanged.")
+	}
+	if got, want := hashingWriter.Sum(), sha512Helper(webBundleBytes); !bytes.Equal(got, want) {
+		t.Errorf("integrityblock: got: %x\nwant: %x", got, want)
+	}
+
+	// Bytes which the underlying writer didn't accept are not hashed.
+	failingHashingWriter := NewHashingWriter(&failAfterWriterHelper{remaining: 0})
+	if _, err := failingHashingWriter.Write(webBundleBytes); err == nil {
+		t.Fatal("Write should fail when the underlying writer fails.")
+	}
+	if got, want := failingHashingWriter.Sum(), sha512Helper(nil); !bytes.Equal(got, want) {
+		t.Errorf("integrityblock: got: %x\nwant: %x", got, want)
+	}
+}
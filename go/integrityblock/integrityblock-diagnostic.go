@@ -0,0 +1,35 @@
+package integrityblock
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DiagnosticString returns the integrity block in CBOR diagnostic notation (EDN), with the byte strings shown
+// as h'...', meant for debugging encoding discrepancies e.g. against the output of cbor.me. The signature
+// attributes are listed in the same canonical order in which `CborBytes` encodes them.
+func (ib *IntegrityBlock) DiagnosticString() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "[h'%x', h'%x', [", ib.Magic, ib.Version)
+	for i, integritySignature := range ib.SignatureStack {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		if integritySignature == nil {
+			sb.WriteString("null")
+			continue
+		}
+
+		sb.WriteString("[{")
+		for j, key := range canonicalAttributeNames(integritySignature.SignatureAttributes) {
+			if j > 0 {
+				sb.WriteString(", ")
+			}
+			fmt.Fprintf(&sb, "%s: h'%x'", strconv.Quote(key), integritySignature.SignatureAttributes[key])
+		}
+		fmt.Fprintf(&sb, "}, h'%x']", integritySignature.Signature)
+	}
+	sb.WriteString("]]")
+	return sb.String()
+}
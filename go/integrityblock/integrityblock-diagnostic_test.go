@@ -0,0 +1,48 @@
+package integrityblock
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/WICG/webpackage/go/internal/cbor"
+)
+
+func TestDiagnosticString(t *testing.T) {
+	integrityBlock := generateEmptyIntegrityBlock()
+	integrityBlock.addNewSignatureToIntegrityBlock(SignatureAttributesMap{Ed25519publicKeyAttributeName: {0x01, 0x02}, "b": {0x03}, "aa": {}}, []byte{0x04})
+	integrityBlock.addNewSignatureToIntegrityBlock(SignatureAttributesMap{Ed25519publicKeyAttributeName: {0x05}}, []byte{0x06, 0x07})
+
+	got := integrityBlock.DiagnosticString()
+	want := `[h'f09f968bf09f93a6', h'31620000', [[{"ed25519PublicKey": h'05'}, h'0607'], [{"b": h'03', "aa": h'', "ed25519PublicKey": h'0102'}, h'04']]]`
+	if got != want {
+		t.Errorf("integrityblock: got: %s\nwant: %s", got, want)
+	}
+
+	if got, want := generateEmptyIntegrityBlock().DiagnosticString(), `[h'f09f968bf09f93a6', h'31620000', []]`; got != want {
+		t.Errorf("integrityblock: got: %s\nwant: %s", got, want)
+	}
+}
+
+func TestCanonicalAttributeNamesMatchEncoding(t *testing.T) {
+	signatureAttributes := SignatureAttributesMap{}
+	for _, key := range []string{"z", "b", "aa", "ab", "c", "ba", Ed25519publicKeyAttributeName, "hello", "a"} {
+		signatureAttributes[key] = []byte{}
+	}
+
+	var got bytes.Buffer
+	if err := signatureAttributes.cborBytes(cbor.NewEncoder(&got)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Map header of 9 entries followed by the entries in the canonical order.
+	want := bytes.NewBuffer([]byte{0xa9})
+	enc := cbor.NewEncoder(want)
+	for _, key := range canonicalAttributeNames(signatureAttributes) {
+		enc.EncodeTextString(key)
+		enc.EncodeByteString(signatureAttributes[key])
+	}
+
+	if !bytes.Equal(got.Bytes(), want.Bytes()) {
+		t.Errorf("integrityblock: got: %x\nwant: %x", got.Bytes(), want.Bytes())
+	}
+}
@@ -0,0 +1,66 @@
+package integrityblock
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+)
+
+// ECDSA P-256 public keys are stored in the signature attributes in the SEC1 compressed form.
+const ecdsaP256CompressedPublicKeySize = 33
+
+// SignIntegrityBlockECDSA creates a new ECDSA P-256 SHA-256 integrity signature over the given integrity block
+// and web bundle hash, analogously to `SignIntegrityBlock`. The signature attributes contain the compressed public
+// key corresponding to the private key and the signature is ASN.1 DER encoded as defined in RFC 3279.
+func SignIntegrityBlockECDSA(ib *IntegrityBlock, privateKey *ecdsa.PrivateKey, webBundleHash []byte) (*IntegritySignature, error) {
+	if privateKey == nil || privateKey.Curve != elliptic.P256() {
+		return nil, errors.New("integrityblock: Private key is not ECDSA P-256 type.")
+	}
+	signatureAttributes := SignatureAttributesMap{
+		EcdsaP256SHA256PublicKeyAttributeName: elliptic.MarshalCompressed(elliptic.P256(), privateKey.X, privateKey.Y),
+	}
+
+	dataToBeSigned, err := GenerateSignedPayload(ib, signatureAttributes, webBundleHash)
+	if err != nil {
+		return nil, err
+	}
+
+	digest := sha256.Sum256(dataToBeSigned)
+	signature, err := ecdsa.SignASN1(rand.Reader, privateKey, digest[:])
+	if err != nil {
+		return nil, err
+	}
+
+	return &IntegritySignature{
+		SignatureAttributes: signatureAttributes,
+		Signature:           signature,
+	}, nil
+}
+
+// VerifyIntegritySignatureECDSA verifies the given ECDSA P-256 SHA-256 integrity signature against the web bundle
+// hash. Like with `VerifyIntegritySignature` the integrity block must be in the state the signature was created over.
+func VerifyIntegritySignatureECDSA(ib *IntegrityBlock, is *IntegritySignature, webBundleHash []byte) (bool, error) {
+	compressedPublicKey, ok := is.EcdsaP256SHA256PublicKey()
+	if !ok {
+		return false, fmt.Errorf("integrityblock: Signature attributes are missing the %q attribute.", EcdsaP256SHA256PublicKeyAttributeName)
+	}
+	if len(compressedPublicKey) != ecdsaP256CompressedPublicKeySize {
+		return false, fmt.Errorf("integrityblock: ECDSA P-256 public key should be %d bytes, got %d bytes.", ecdsaP256CompressedPublicKeySize, len(compressedPublicKey))
+	}
+	x, y := elliptic.UnmarshalCompressed(elliptic.P256(), compressedPublicKey)
+	if x == nil {
+		return false, errors.New("integrityblock: Invalid ECDSA P-256 public key.")
+	}
+	publicKey := &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+
+	dataToBeSigned, err := GenerateSignedPayload(ib, is.SignatureAttributes, webBundleHash)
+	if err != nil {
+		return false, err
+	}
+
+	digest := sha256.Sum256(dataToBeSigned)
+	return ecdsa.VerifyASN1(publicKey, digest[:], is.Signature), nil
+}
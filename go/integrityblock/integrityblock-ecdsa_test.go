@@ -0,0 +1,80 @@
+package integrityblock
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+)
+
+func TestSignAndVerifyIntegritySignatureECDSA(t *testing.T) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal("Failed to generate test keys")
+	}
+	webBundleHash := sha512Helper([]byte("webbundle"))
+
+	integrityBlock := generateEmptyIntegrityBlock()
+	integritySignature, err := SignIntegrityBlockECDSA(integrityBlock, privateKey, webBundleHash)
+	if err != nil {
+		t.Fatalf("SignIntegrityBlockECDSA. err: %v", err)
+	}
+	if len(integritySignature.SignatureAttributes[EcdsaP256SHA256PublicKeyAttributeName]) != ecdsaP256CompressedPublicKeySize {
+		t.Error("Signature attributes should contain the compressed public key of the signer.")
+	}
+
+	ok, err := VerifyIntegritySignatureECDSA(integrityBlock, integritySignature, webBundleHash)
+	if err != nil {
+		t.Fatalf("VerifyIntegritySignatureECDSA. err: %v", err)
+	}
+	if !ok {
+		t.Error("Signature should be valid.")
+	}
+
+	ok, err = VerifyIntegritySignatureECDSA(integrityBlock, integritySignature, sha512Helper([]byte("otherwebbundle")))
+	if err != nil {
+		t.Fatalf("VerifyIntegritySignatureECDSA. err: %v", err)
+	}
+	if ok {
+		t.Error("Signature should not be valid for a different web bundle hash.")
+	}
+}
+
+func TestVerifyIntegrityBlockWithMixedAlgorithms(t *testing.T) {
+	ecdsaPrivateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal("Failed to generate test keys")
+	}
+	_, ed25519PrivateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal("Failed to generate test keys")
+	}
+	webBundleHash := sha512Helper([]byte("webbundle"))
+
+	integrityBlock := generateEmptyIntegrityBlock()
+	ecdsaSignature, err := SignIntegrityBlockECDSA(integrityBlock, ecdsaPrivateKey, webBundleHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	AppendSignature(integrityBlock, ecdsaSignature)
+
+	ed25519Signature, err := SignIntegrityBlock(integrityBlock, NewParsedEd25519KeySigningStrategy(ed25519PrivateKey), webBundleHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	AppendSignature(integrityBlock, ed25519Signature)
+
+	if err := integrityBlock.Validate(); err != nil {
+		t.Errorf("Validate. err: %v", err)
+	}
+
+	result, err := VerifyIntegrityBlock(integrityBlock, webBundleHash)
+	if err != nil {
+		t.Fatalf("VerifyIntegrityBlock. err: %v", err)
+	}
+	valid, invalid := result.ValidSignatures(), result.InvalidSignatures()
+	if len(valid) != 2 || len(invalid) != 0 {
+		t.Errorf("integrityblock: got %d valid and %d invalid signatures, want 2 valid", len(valid), len(invalid))
+	}
+}
@@ -0,0 +1,128 @@
+package integrityblock
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/WICG/webpackage/go/internal/cbor"
+)
+
+// integrityBlockJSON is the JSON representation of the integrity block meant for debugging purposes.
+// Magic and version are shown as hex strings as they are meant to be compared against the spec.
+type integrityBlockJSON struct {
+	Magic          string                `json:"magic"`
+	Version        string                `json:"version"`
+	SignatureStack []*IntegritySignature `json:"signatureStack"`
+}
+
+// integritySignatureJSON is the JSON representation of the integrity signature, where the attribute
+// values and the signature are shown as standard base64.
+type integritySignatureJSON struct {
+	SignatureAttributes map[string]string `json:"signatureAttributes"`
+	Signature           string            `json:"signature"`
+}
+
+// MarshalJSON implements json.Marshaler so that the integrity block can be dumped with json.MarshalIndent.
+func (ib *IntegrityBlock) MarshalJSON() ([]byte, error) {
+	signatureStack := ib.SignatureStack
+	if signatureStack == nil {
+		signatureStack = []*IntegritySignature{}
+	}
+	return json.Marshal(integrityBlockJSON{
+		Magic:          hex.EncodeToString(ib.Magic),
+		Version:        hex.EncodeToString(ib.Version),
+		SignatureStack: signatureStack,
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler and is the inverse of MarshalJSON.
+func (ib *IntegrityBlock) UnmarshalJSON(data []byte) error {
+	var ibJSON integrityBlockJSON
+	if err := json.Unmarshal(data, &ibJSON); err != nil {
+		return err
+	}
+
+	magic, err := hex.DecodeString(ibJSON.Magic)
+	if err != nil {
+		return fmt.Errorf("integrityblock: Failed to decode magic: %v", err)
+	}
+	version, err := hex.DecodeString(ibJSON.Version)
+	if err != nil {
+		return fmt.Errorf("integrityblock: Failed to decode version: %v", err)
+	}
+
+	ib.Magic = magic
+	ib.Version = version
+	ib.SignatureStack = ibJSON.SignatureStack
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler for the integrity signature.
+func (is *IntegritySignature) MarshalJSON() ([]byte, error) {
+	signatureAttributes := make(map[string]string, len(is.SignatureAttributes))
+	for key, value := range is.SignatureAttributes {
+		signatureAttributes[key] = base64.StdEncoding.EncodeToString(value)
+	}
+	return json.Marshal(integritySignatureJSON{
+		SignatureAttributes: signatureAttributes,
+		Signature:           base64.StdEncoding.EncodeToString(is.Signature),
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler and is the inverse of MarshalJSON.
+func (is *IntegritySignature) UnmarshalJSON(data []byte) error {
+	var isJSON integritySignatureJSON
+	if err := json.Unmarshal(data, &isJSON); err != nil {
+		return err
+	}
+
+	signatureAttributes := make(SignatureAttributesMap, len(isJSON.SignatureAttributes))
+	for key, value := range isJSON.SignatureAttributes {
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return fmt.Errorf("integrityblock: Failed to decode signature attribute %q: %v", key, err)
+		}
+		signatureAttributes[key] = decoded
+	}
+	signature, err := base64.StdEncoding.DecodeString(isJSON.Signature)
+	if err != nil {
+		return fmt.Errorf("integrityblock: Failed to decode signature: %v", err)
+	}
+
+	is.SignatureAttributes = signatureAttributes
+	is.Signature = signature
+	return nil
+}
+
+// Base64URL returns the CBOR encoded integrity signature as unpadded base64url, which is a compact text
+// representation for carrying the signature e.g. in JSON manifests. `ParseIntegritySignatureBase64URL`
+// is the inverse.
+func (is *IntegritySignature) Base64URL() (string, error) {
+	var buf bytes.Buffer
+	if err := is.cborBytes(cbor.NewEncoder(&buf)); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// ParseIntegritySignatureBase64URL decodes an integrity signature from the unpadded base64url string returned
+// by `IntegritySignature.Base64URL`.
+func ParseIntegritySignatureBase64URL(s string) (*IntegritySignature, error) {
+	cborBytes, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("integrityblock: Failed to decode base64url integrity signature: %v", err)
+	}
+
+	r := bytes.NewReader(cborBytes)
+	integritySignature, err := parseIntegritySignature(cbor.NewDecoder(r), ParseOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("integrityblock: %v", err)
+	}
+	if r.Len() != 0 {
+		return nil, fmt.Errorf("integrityblock: Integrity signature is followed by %d unexpected bytes.", r.Len())
+	}
+	return integritySignature, nil
+}
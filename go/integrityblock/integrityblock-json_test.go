@@ -0,0 +1,108 @@
+package integrityblock
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestIntegrityBlockMarshalJSON(t *testing.T) {
+	integrityBlock := generateEmptyIntegrityBlock()
+	integrityBlock.addNewSignatureToIntegrityBlock(SignatureAttributesMap{Ed25519publicKeyAttributeName: []byte("publickey")}, []byte("signature"))
+
+	got, err := json.Marshal(integrityBlock)
+	if err != nil {
+		t.Fatalf("json.Marshal. err: %v", err)
+	}
+
+	want := `{"magic":"f09f968bf09f93a6","version":"31620000","signatureStack":[{"signatureAttributes":{"ed25519PublicKey":"cHVibGlja2V5"},"signature":"c2lnbmF0dXJl"}]}`
+	if string(got) != want {
+		t.Errorf("integrityblock: got: %s\nwant: %s", got, want)
+	}
+}
+
+func TestIntegrityBlockJSONRoundTrip(t *testing.T) {
+	integrityBlock := generateEmptyIntegrityBlock()
+	integrityBlock.addNewSignatureToIntegrityBlock(SignatureAttributesMap{Ed25519publicKeyAttributeName: []byte("publickey1"), "hello": []byte("world")}, []byte("signature1"))
+	integrityBlock.addNewSignatureToIntegrityBlock(SignatureAttributesMap{Ed25519publicKeyAttributeName: []byte("publickey2")}, []byte("signature2"))
+
+	jsonBytes, err := json.MarshalIndent(integrityBlock, "", "  ")
+	if err != nil {
+		t.Fatalf("json.MarshalIndent. err: %v", err)
+	}
+
+	var got IntegrityBlock
+	if err := json.Unmarshal(jsonBytes, &got); err != nil {
+		t.Fatalf("json.Unmarshal. err: %v", err)
+	}
+
+	gotBytes, err := got.CborBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantBytes, err := integrityBlock.CborBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(gotBytes, wantBytes) {
+		t.Errorf("integrityblock: got: %x\nwant: %x", gotBytes, wantBytes)
+	}
+}
+
+func TestIntegritySignatureBase64URLRoundTrip(t *testing.T) {
+	// Bytes which produce both '-' and '_' in base64url and would need padding in standard base64.
+	want := &IntegritySignature{
+		SignatureAttributes: SignatureAttributesMap{Ed25519publicKeyAttributeName: {0xfb, 0xff, 0xfe}},
+		Signature:           []byte{0xfb, 0xef, 0xbe, 0xff},
+	}
+
+	encoded, err := want.Base64URL()
+	if err != nil {
+		t.Fatalf("Base64URL. err: %v", err)
+	}
+	if strings.ContainsAny(encoded, "+/=") {
+		t.Errorf("integrityblock: got %q, which is not unpadded base64url", encoded)
+	}
+
+	got, err := ParseIntegritySignatureBase64URL(encoded)
+	if err != nil {
+		t.Fatalf("ParseIntegritySignatureBase64URL. err: %v", err)
+	}
+	if !bytes.Equal(got.Signature, want.Signature) {
+		t.Errorf("integrityblock: got signature: %x\nwant: %x", got.Signature, want.Signature)
+	}
+	if !bytes.Equal(got.SignatureAttributes[Ed25519publicKeyAttributeName], want.SignatureAttributes[Ed25519publicKeyAttributeName]) {
+		t.Errorf("integrityblock: got public key: %x\nwant: %x", got.SignatureAttributes[Ed25519publicKeyAttributeName], want.SignatureAttributes[Ed25519publicKeyAttributeName])
+	}
+}
+
+func TestParseIntegritySignatureBase64URLWithInvalidInput(t *testing.T) {
+	integritySignature := &IntegritySignature{
+		SignatureAttributes: SignatureAttributesMap{Ed25519publicKeyAttributeName: []byte("publickey")},
+		Signature:           []byte("signature"),
+	}
+	encoded, err := integritySignature.Base64URL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cborBytes, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	invalidInputs := map[string]string{
+		"Not base64url":  "c2lnbmF0dXJl+/",
+		"Padded":         base64.URLEncoding.EncodeToString(cborBytes[:len(cborBytes)-1]),
+		"Truncated":      base64.RawURLEncoding.EncodeToString(cborBytes[:len(cborBytes)-1]),
+		"Trailing bytes": base64.RawURLEncoding.EncodeToString(append(cborBytes, 0x00)),
+	}
+	for name, input := range invalidInputs {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParseIntegritySignatureBase64URL(input); err == nil {
+				t.Errorf("ParseIntegritySignatureBase64URL should fail with %q.", input)
+			}
+		})
+	}
+}
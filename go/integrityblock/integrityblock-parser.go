@@ -0,0 +1,276 @@
+package integrityblock
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/WICG/webpackage/go/internal/cbor"
+)
+
+// ParseOptions controls how strictly `ParseIntegrityBlockWithOptions` validates the integrity block.
+type ParseOptions struct {
+	// Strict makes the parser reject signature attributes maps whose keys are not in the canonical
+	// CBOR order. A non-canonical integrity block produces a different signed payload when re-encoded,
+	// which a verifier reconstructing the payload would not expect.
+	Strict bool
+
+	// PreserveUnknownFields makes the parser accept integrity block arrays with more than three elements, as
+	// newer integrity block versions might append fields. The additional elements are kept as raw CBOR in
+	// `IntegrityBlock.UnknownFields` instead of failing with `ErrUnexpectedIntegrityBlockSize`.
+	PreserveUnknownFields bool
+}
+
+// maxPreallocatedSignatures caps the capacity preallocated for the signature stack, as a malicious integrity
+// block could declare billions of signatures. Longer signature stacks grow the slice as they are parsed.
+const maxPreallocatedSignatures = 16
+
+// ParseIntegrityBlock reads the CBOR encoded integrity block from the given reader. The integrity block
+// is expected to be an array of three elements: magic, version and the signature stack. An array of any other
+// length fails with `ErrUnexpectedIntegrityBlockSize`. Every item must be of the expected CBOR major type, and
+// the error of an item of another type names both the expected and the found type. The reader is left
+// positioned right after the integrity block, meaning at the start of the web bundle bytes.
+func ParseIntegrityBlock(r io.Reader) (*IntegrityBlock, error) {
+	return ParseIntegrityBlockWithOptions(r, ParseOptions{})
+}
+
+// ParseIntegrityBlockWithOptions is like `ParseIntegrityBlock`, but the validation is controlled by `opts`.
+func ParseIntegrityBlockWithOptions(r io.Reader, opts ParseOptions) (*IntegrityBlock, error) {
+	dec := cbor.NewDecoder(r)
+
+	n, err := dec.DecodeArrayHeader()
+	if err != nil {
+		return nil, fmt.Errorf("integrityblock: Failed to decode integrity block array header: %v", err)
+	}
+	if n > 3 && !opts.PreserveUnknownFields {
+		// A newer integrity block version might append fields which this parser doesn't know how to interpret.
+		return nil, fmt.Errorf("%w Got %d elements, the integrity block may have been produced for a newer version.", ErrUnexpectedIntegrityBlockSize, n)
+	}
+	if n < 3 {
+		return nil, fmt.Errorf("%w Got only %d elements.", ErrUnexpectedIntegrityBlockSize, n)
+	}
+
+	magic, err := dec.DecodeByteString()
+	if err != nil {
+		return nil, fmt.Errorf("integrityblock: Failed to decode magic: %v", err)
+	}
+	if !bytes.Equal(magic, IntegrityBlockMagic) {
+		return nil, fmt.Errorf("integrityblock: Unexpected magic %x.", magic)
+	}
+
+	version, err := dec.DecodeByteString()
+	if err != nil {
+		return nil, fmt.Errorf("integrityblock: Failed to decode version: %v", err)
+	}
+	if !IsSupportedVersion(version) {
+		return nil, fmt.Errorf("integrityblock: Unsupported version %x.", version)
+	}
+
+	numSignatures, err := dec.DecodeArrayHeader()
+	if err != nil {
+		return nil, fmt.Errorf("integrityblock: Failed to decode signature stack array header: %v", err)
+	}
+
+	// The declared number of signatures comes from untrusted input, so it only limits the preallocation.
+	preallocatedSignatures := numSignatures
+	if preallocatedSignatures > maxPreallocatedSignatures {
+		preallocatedSignatures = maxPreallocatedSignatures
+	}
+	signatureStack := make([]*IntegritySignature, 0, preallocatedSignatures)
+	for i := uint64(0); i < numSignatures; i++ {
+		integritySignature, err := parseIntegritySignature(dec, opts)
+		if err != nil {
+			return nil, fmt.Errorf("integrityblock: signatureStack[%d]: %v", i, err)
+		}
+		signatureStack = append(signatureStack, integritySignature)
+	}
+
+	var unknownFields [][]byte
+	for i := uint64(3); i < n; i++ {
+		unknownField, err := dec.DecodeRawItem()
+		if err != nil {
+			return nil, fmt.Errorf("integrityblock: Failed to decode unknown field %d: %v", i, err)
+		}
+		unknownFields = append(unknownFields, unknownField)
+	}
+
+	return &IntegrityBlock{
+		Magic:          magic,
+		Version:        version,
+		SignatureStack: signatureStack,
+		UnknownFields:  unknownFields,
+	}, nil
+}
+
+// PeekVersion reads only the integrity block array header, the magic and the version from the given reader and
+// returns the version, so that a dispatcher can pick the handler for the integrity block cheaply. The signature
+// stack is neither read nor required, and the version is returned even if it is not supported by this package.
+// The reader is left positioned right after the version.
+func PeekVersion(r io.Reader) ([]byte, error) {
+	dec := cbor.NewDecoder(r)
+
+	n, err := dec.DecodeArrayHeader()
+	if err != nil {
+		return nil, fmt.Errorf("integrityblock: Failed to decode integrity block array header: %v", err)
+	}
+	if n < 3 {
+		return nil, fmt.Errorf("%w Got only %d elements.", ErrUnexpectedIntegrityBlockSize, n)
+	}
+
+	magic, err := dec.DecodeByteString()
+	if err != nil {
+		return nil, fmt.Errorf("integrityblock: Failed to decode magic: %v", err)
+	}
+	if !bytes.Equal(magic, IntegrityBlockMagic) {
+		return nil, fmt.Errorf("integrityblock: Unexpected magic %x.", magic)
+	}
+
+	version, err := dec.DecodeByteString()
+	if err != nil {
+		return nil, fmt.Errorf("integrityblock: Failed to decode version: %v", err)
+	}
+	return version, nil
+}
+
+// VerifyCanonicalEncoding parses the integrity block at the start of the signed web bundle read from `signed`
+// and checks that re-encoding it reproduces the original bytes exactly. It fails with `ErrNonCanonicalEncoding`
+// if they differ, e.g. because of non-minimal lengths or map keys out of the canonical order, as a verifier
+// reconstructing the signed payload always uses the canonical encoding. Only the integrity block is read.
+func VerifyCanonicalEncoding(signed io.Reader) error {
+	var original bytes.Buffer
+	integrityBlock, err := ParseIntegrityBlock(teeReader(signed, &original))
+	if err != nil {
+		return err
+	}
+
+	return checkCanonicalEncoding(integrityBlock, original.Bytes())
+}
+
+// teeReader is like io.TeeReader, but keeps the size of the remaining input known to the CBOR decoder if r
+// knows it, so that the declared lengths are still validated against the input.
+func teeReader(r io.Reader, w io.Writer) io.Reader {
+	tee := io.TeeReader(r, w)
+	if remaining, ok := cbor.RemainingLen(r); ok {
+		return io.LimitReader(tee, remaining)
+	}
+	return tee
+}
+
+// checkCanonicalEncoding fails with `ErrNonCanonicalEncoding` if re-encoding the parsed integrity block doesn't
+// reproduce the original bytes it was parsed from.
+func checkCanonicalEncoding(integrityBlock *IntegrityBlock, original []byte) error {
+	reencoded, err := integrityBlock.CborBytes()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(reencoded, original) {
+		offset := 0
+		for offset < len(reencoded) && offset < len(original) && reencoded[offset] == original[offset] {
+			offset++
+		}
+		return fmt.Errorf("%w Re-encoded integrity block differs from the original at byte %d.", ErrNonCanonicalEncoding, offset)
+	}
+	return nil
+}
+
+// ParseIntegrityBlockStrict is like `ParseIntegrityBlock`, but applies every available check for ingesting
+// untrusted integrity blocks. On top of what `ParseIntegrityBlock` checks, it requires that:
+//   - the integrity block is encoded canonically, meaning that re-encoding it reproduces the original bytes,
+//     see `VerifyCanonicalEncoding`,
+//   - the signature attributes contain only attributes known to this package, see `LookupAttribute`, and
+//     their values have the registered sizes,
+//   - every integrity signature has exactly one public key attribute and a signature of the size of its
+//     signing algorithm, see `Validate`.
+//
+// Both parsers reject integrity block arrays of other than three elements, an unexpected magic and unsupported
+// versions, whereas only `ParseIntegrityBlock` keeps unknown attributes and tolerates non-canonical encodings.
+func ParseIntegrityBlockStrict(r io.Reader) (*IntegrityBlock, error) {
+	var original bytes.Buffer
+	integrityBlock, err := ParseIntegrityBlockWithOptions(teeReader(r, &original), ParseOptions{Strict: true})
+	if err != nil {
+		return nil, err
+	}
+	if err := checkCanonicalEncoding(integrityBlock, original.Bytes()); err != nil {
+		return nil, err
+	}
+
+	for i, integritySignature := range integrityBlock.SignatureStack {
+		for _, name := range canonicalAttributeNames(integritySignature.SignatureAttributes) {
+			if err := checkRegisteredAttribute(name, integritySignature.SignatureAttributes[name]); err != nil {
+				return nil, fmt.Errorf("integrityblock: signatureStack[%d]: %v", i, err)
+			}
+		}
+	}
+	if err := integrityBlock.Validate(); err != nil {
+		return nil, err
+	}
+	return integrityBlock, nil
+}
+
+// parseIntegritySignature decodes an integrity signature, which is an array of two elements: the
+// signature attributes map and the signature.
+func parseIntegritySignature(dec *cbor.Decoder, opts ParseOptions) (*IntegritySignature, error) {
+	n, err := dec.DecodeArrayHeader()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decode integrity signature array header: %v", err)
+	}
+	if n != 2 {
+		return nil, fmt.Errorf("Integrity signature array should have 2 elements, got %d.", n)
+	}
+
+	signatureAttributes, err := parseSignatureAttributes(dec, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := dec.DecodeByteString()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decode signature: %v", err)
+	}
+
+	return &IntegritySignature{
+		SignatureAttributes: signatureAttributes,
+		Signature:           signature,
+	}, nil
+}
+
+// parseSignatureAttributes decodes the signature attributes map whose keys are text strings and values byte strings.
+// All the attributes are kept, including the ones this package doesn't know about, as they are covered by the
+// signature and must survive re-encoding.
+func parseSignatureAttributes(dec *cbor.Decoder, opts ParseOptions) (SignatureAttributesMap, error) {
+	signatureAttributes := make(SignatureAttributesMap)
+	var lastKeyBytes []byte
+
+	err := dec.DecodeMap(func(dec *cbor.Decoder) error {
+		key, err := dec.DecodeTextString()
+		if err != nil {
+			return fmt.Errorf("Failed to decode signature attributes map key: %v", err)
+		}
+		if _, exists := signatureAttributes[key]; exists {
+			return fmt.Errorf("Signature attribute %q appeared twice.", key)
+		}
+
+		if opts.Strict {
+			// Keys must be sorted in the bytewise lexicographic order of their CBOR encodings.
+			var keyBuf bytes.Buffer
+			if err := cbor.NewEncoder(&keyBuf).EncodeTextString(key); err != nil {
+				return err
+			}
+			if lastKeyBytes != nil && bytes.Compare(lastKeyBytes, keyBuf.Bytes()) > 0 {
+				return fmt.Errorf("Signature attribute %q is not in canonical order.", key)
+			}
+			lastKeyBytes = keyBuf.Bytes()
+		}
+
+		value, err := dec.DecodeByteString()
+		if err != nil {
+			return fmt.Errorf("Failed to decode signature attribute %q: %v", key, err)
+		}
+		signatureAttributes[key] = value
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decode signature attributes: %v", err)
+	}
+	return signatureAttributes, nil
+}
@@ -0,0 +1,586 @@
+package integrityblock
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/WICG/webpackage/go/internal/cbor"
+)
+
+func TestParseEmptyIntegrityBlock(t *testing.T) {
+	integrityBlockBytes, err := generateEmptyIntegrityBlock().CborBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ParseIntegrityBlock(bytes.NewReader(integrityBlockBytes))
+	if err != nil {
+		t.Fatalf("ParseIntegrityBlock. err: %v", err)
+	}
+
+	if !bytes.Equal(got.Magic, IntegrityBlockMagic) {
+		t.Errorf("integrityblock: got magic: %v\nwant: %v", got.Magic, IntegrityBlockMagic)
+	}
+	if !bytes.Equal(got.Version, VersionB1) {
+		t.Errorf("integrityblock: got version: %v\nwant: %v", got.Version, VersionB1)
+	}
+	if len(got.SignatureStack) != 0 {
+		t.Errorf("integrityblock: got %d signatures, want 0", len(got.SignatureStack))
+	}
+}
+
+func TestParseIntegrityBlockWithSignatures(t *testing.T) {
+	integrityBlock := generateEmptyIntegrityBlock()
+	integrityBlock.addNewSignatureToIntegrityBlock(SignatureAttributesMap{Ed25519publicKeyAttributeName: []byte("publickey1"), "hello": []byte("world")}, []byte("signature1"))
+	integrityBlock.addNewSignatureToIntegrityBlock(SignatureAttributesMap{Ed25519publicKeyAttributeName: []byte("publickey2")}, []byte("signature2"))
+
+	integrityBlockBytes, err := integrityBlock.CborBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := bytes.NewReader(append(integrityBlockBytes, []byte("webbundle")...))
+	got, err := ParseIntegrityBlock(r)
+	if err != nil {
+		t.Fatalf("ParseIntegrityBlock. err: %v", err)
+	}
+
+	if len(got.SignatureStack) != len(integrityBlock.SignatureStack) {
+		t.Fatalf("integrityblock: got %d signatures, want %d", len(got.SignatureStack), len(integrityBlock.SignatureStack))
+	}
+	for i, want := range integrityBlock.SignatureStack {
+		gotSignature := got.SignatureStack[i]
+		if !bytes.Equal(gotSignature.Signature, want.Signature) {
+			t.Errorf("integrityblock: signatureStack[%d] got signature: %q\nwant: %q", i, gotSignature.Signature, want.Signature)
+		}
+		if len(gotSignature.SignatureAttributes) != len(want.SignatureAttributes) {
+			t.Errorf("integrityblock: signatureStack[%d] got %d attributes, want %d", i, len(gotSignature.SignatureAttributes), len(want.SignatureAttributes))
+		}
+		for key, value := range want.SignatureAttributes {
+			if !bytes.Equal(gotSignature.SignatureAttributes[key], value) {
+				t.Errorf("integrityblock: signatureStack[%d] got attribute %q: %q\nwant: %q", i, key, gotSignature.SignatureAttributes[key], value)
+			}
+		}
+	}
+
+	// The reader should be positioned at the start of the web bundle bytes.
+	if r.Len() != len("webbundle") {
+		t.Errorf("integrityblock: got %d bytes left in the reader, want %d", r.Len(), len("webbundle"))
+	}
+}
+
+func TestParseIntegrityBlockWithInvalidMagic(t *testing.T) {
+	integrityBlock := generateEmptyIntegrityBlock()
+	integrityBlock.Magic = []byte("notmagic")
+
+	integrityBlockBytes, err := integrityBlock.CborBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = ParseIntegrityBlock(bytes.NewReader(integrityBlockBytes))
+	if err == nil {
+		t.Fatal("ParseIntegrityBlock should fail with invalid magic.")
+	}
+	if !strings.Contains(err.Error(), "6e6f746d61676963") {
+		t.Errorf("integrityblock: error should contain the magic as hex, got: %v", err)
+	}
+}
+
+func TestParseIntegrityBlockWithUnsupportedVersion(t *testing.T) {
+	integrityBlock := generateEmptyIntegrityBlock()
+	integrityBlock.Version = []byte{0x39, 0x39, 0x00, 0x00}
+
+	integrityBlockBytes, err := integrityBlock.CborBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = ParseIntegrityBlock(bytes.NewReader(integrityBlockBytes))
+	if err == nil {
+		t.Fatal("ParseIntegrityBlock should fail with unsupported version.")
+	}
+	if !strings.Contains(err.Error(), "39390000") {
+		t.Errorf("integrityblock: error should contain the version as hex, got: %v", err)
+	}
+}
+
+func TestParseTruncatedIntegrityBlock(t *testing.T) {
+	integrityBlock := generateEmptyIntegrityBlock()
+	integrityBlock.addNewSignatureToIntegrityBlock(SignatureAttributesMap{Ed25519publicKeyAttributeName: []byte("publickey")}, []byte("signature"))
+
+	integrityBlockBytes, err := integrityBlock.CborBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < len(integrityBlockBytes); i++ {
+		if _, err := ParseIntegrityBlock(bytes.NewReader(integrityBlockBytes[:i])); err == nil {
+			t.Errorf("ParseIntegrityBlock should fail with integrity block truncated to %d bytes.", i)
+		}
+	}
+}
+
+func TestParseIntegrityBlockWithUnexpectedArrayLength(t *testing.T) {
+	integrityBlockBytes, err := generateEmptyIntegrityBlock().CborBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// ["🖋📦" "1b\x00\x00" [] h''], an integrity block with an additional trailing field.
+	withExtraField := append([]byte{0x84}, integrityBlockBytes[1:]...)
+	withExtraField = append(withExtraField, 0x40)
+
+	// ["🖋📦" "1b\x00\x00"]
+	withMissingField := append([]byte{0x82}, integrityBlockBytes[1:len(integrityBlockBytes)-1]...)
+
+	tests := []struct {
+		name       string
+		input      []byte
+		wantSubstr string
+	}{
+		{"extra field", withExtraField, "Got 4 elements"},
+		{"missing field", withMissingField, "Got only 2 elements"},
+		{"empty array", []byte{0x80}, "Got only 0 elements"},
+	}
+	for _, test := range tests {
+		_, err := ParseIntegrityBlock(bytes.NewReader(test.input))
+		if !errors.Is(err, ErrUnexpectedIntegrityBlockSize) {
+			t.Errorf("%s: got err: %v\nwant: %v", test.name, err, ErrUnexpectedIntegrityBlockSize)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.wantSubstr) {
+			t.Errorf("%s: error should contain %q, got: %v", test.name, test.wantSubstr, err)
+		}
+	}
+}
+
+func TestParseIntegrityBlockPreservingUnknownFields(t *testing.T) {
+	integrityBlock := generateEmptyIntegrityBlock()
+	integrityBlock.addNewSignatureToIntegrityBlock(SignatureAttributesMap{Ed25519publicKeyAttributeName: []byte("publickey")}, []byte("signature"))
+	integrityBlockBytes, err := integrityBlock.CborBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Two additional fields: {"a": [1, h'02']} and 24(h'03').
+	unknownFields := [][]byte{{0xa1, 0x61, 'a', 0x82, 0x01, 0x41, 0x02}, {0xd8, 0x18, 0x41, 0x03}}
+	withUnknownFields := append([]byte{0x85}, integrityBlockBytes[1:]...)
+	for _, unknownField := range unknownFields {
+		withUnknownFields = append(withUnknownFields, unknownField...)
+	}
+
+	if _, err := ParseIntegrityBlock(bytes.NewReader(withUnknownFields)); !errors.Is(err, ErrUnexpectedIntegrityBlockSize) {
+		t.Errorf("integrityblock: got err: %v\nwant: %v", err, ErrUnexpectedIntegrityBlockSize)
+	}
+
+	r := bytes.NewReader(append(bytes.Clone(withUnknownFields), []byte("webbundle")...))
+	parsed, err := ParseIntegrityBlockWithOptions(r, ParseOptions{PreserveUnknownFields: true})
+	if err != nil {
+		t.Fatalf("ParseIntegrityBlockWithOptions. err: %v", err)
+	}
+	if r.Len() != len("webbundle") {
+		t.Errorf("integrityblock: reader should be positioned after the integrity block, %d bytes left", r.Len())
+	}
+	if len(parsed.UnknownFields) != len(unknownFields) {
+		t.Fatalf("integrityblock: got %d unknown fields, want %d", len(parsed.UnknownFields), len(unknownFields))
+	}
+	for i := range unknownFields {
+		if !bytes.Equal(parsed.UnknownFields[i], unknownFields[i]) {
+			t.Errorf("integrityblock: unknown field %d got: %x\nwant: %x", i, parsed.UnknownFields[i], unknownFields[i])
+		}
+	}
+
+	preserved, err := parsed.CborBytesPreservingUnknownFields()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(preserved, withUnknownFields) {
+		t.Errorf("integrityblock: got: %x\nwant: %x", preserved, withUnknownFields)
+	}
+	// The unknown fields are not part of the default encoding, which the signatures cover.
+	got, err := parsed.CborBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, integrityBlockBytes) {
+		t.Errorf("integrityblock: got: %x\nwant: %x", got, integrityBlockBytes)
+	}
+
+	// Without unknown fields, preserving them encodes exactly like `CborBytes`.
+	preserved, err = integrityBlock.CborBytesPreservingUnknownFields()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(preserved, integrityBlockBytes) {
+		t.Errorf("integrityblock: got: %x\nwant: %x", preserved, integrityBlockBytes)
+	}
+
+	// Unknown fields which are not exactly one CBOR item would corrupt the integrity block array.
+	for _, unknownField := range [][]byte{{}, {0x43, 'a'}, {0x01, 0x02}} {
+		parsed.UnknownFields = [][]byte{unknownField}
+		if _, err := parsed.CborBytesPreservingUnknownFields(); !errors.Is(err, ErrCborEncode) {
+			t.Errorf("integrityblock: unknown field %x got err: %v\nwant: %v", unknownField, err, ErrCborEncode)
+		}
+	}
+
+	truncated := withUnknownFields[:len(withUnknownFields)-1]
+	if _, err := ParseIntegrityBlockWithOptions(bytes.NewReader(truncated), ParseOptions{PreserveUnknownFields: true}); err == nil {
+		t.Error("ParseIntegrityBlockWithOptions should fail with a truncated unknown field.")
+	}
+}
+
+func TestParseIntegrityBlockWithUnexpectedMajorTypes(t *testing.T) {
+	withHeader := func(rest ...byte) []byte {
+		b := []byte{0x83, 0x48}
+		b = append(b, IntegrityBlockMagic...)
+		b = append(b, 0x44)
+		b = append(b, VersionB1...)
+		return append(b, rest...)
+	}
+	magicAsTextString := append([]byte{0x83, 0x68}, IntegrityBlockMagic...)
+	magicAsTextString = append(magicAsTextString, 0x44)
+	magicAsTextString = append(magicAsTextString, VersionB1...)
+	magicAsTextString = append(magicAsTextString, 0x80)
+	versionAsUint := append([]byte{0x83, 0x48}, IntegrityBlockMagic...)
+	versionAsUint = append(versionAsUint, 0x01, 0x80)
+
+	tests := []struct {
+		name       string
+		input      []byte
+		wantSubstr string
+	}{
+		{"integrity block as map", []byte{0xa0}, "Expected array, got map."},
+		{"magic as text string", magicAsTextString, "magic: cbor: Expected byte string, got text string."},
+		{"version as unsigned integer", versionAsUint, "version: cbor: Expected byte string, got unsigned integer."},
+		{"signature stack as map", withHeader(0xa0), "signature stack array header: cbor: Expected array, got map."},
+		{"integrity signature as byte string", withHeader(0x81, 0x40), "integrity signature array header: cbor: Expected array, got byte string."},
+		{"signature attributes as array", withHeader(0x81, 0x82, 0x80, 0x40), "Expected map, got array."},
+		{"signature attribute key as byte string", withHeader(0x81, 0x82, 0xa1, 0x41, 'k', 0x41, 'v', 0x40), "Expected text string, got byte string."},
+		{"signature attribute value as text string", withHeader(0x81, 0x82, 0xa1, 0x61, 'k', 0x61, 'v', 0x40), "Expected byte string, got text string."},
+		{"signature as text string", withHeader(0x81, 0x82, 0xa0, 0x60), "signature: cbor: Expected byte string, got text string."},
+	}
+	for _, test := range tests {
+		_, err := ParseIntegrityBlock(bytes.NewReader(test.input))
+		if err == nil {
+			t.Errorf("%s: ParseIntegrityBlock should fail", test.name)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.wantSubstr) {
+			t.Errorf("%s: error should contain %q, got: %v", test.name, test.wantSubstr, err)
+		}
+	}
+}
+
+func TestParseIntegrityBlockWithVersionB2(t *testing.T) {
+	integrityBlock := generateEmptyIntegrityBlock()
+	integrityBlock.Version = VersionB2
+
+	integrityBlockBytes, err := integrityBlock.CborBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ParseIntegrityBlock(bytes.NewReader(integrityBlockBytes))
+	if err != nil {
+		t.Fatalf("ParseIntegrityBlock. err: %v", err)
+	}
+	if got.VersionString() != "b2" {
+		t.Errorf("integrityblock: got version: %s\nwant: b2", got.VersionString())
+	}
+}
+
+func TestParseIntegrityBlockWithNonCanonicalAttributes(t *testing.T) {
+	// ["🖋📦" "1b\x00\x00" [[map["hello":"world" "a":"b"] "signature"]]], where the keys are not in canonical order.
+	integrityBlockBytes := []byte{0x83, 0x48}
+	integrityBlockBytes = append(integrityBlockBytes, IntegrityBlockMagic...)
+	integrityBlockBytes = append(integrityBlockBytes, 0x44)
+	integrityBlockBytes = append(integrityBlockBytes, VersionB1...)
+	integrityBlockBytes = append(integrityBlockBytes, 0x81, 0x82, 0xa2)
+	integrityBlockBytes = append(integrityBlockBytes, 0x65, 'h', 'e', 'l', 'l', 'o', 0x45, 'w', 'o', 'r', 'l', 'd')
+	integrityBlockBytes = append(integrityBlockBytes, 0x61, 'a', 0x41, 'b')
+	integrityBlockBytes = append(integrityBlockBytes, 0x49, 's', 'i', 'g', 'n', 'a', 't', 'u', 'r', 'e')
+
+	if _, err := ParseIntegrityBlock(bytes.NewReader(integrityBlockBytes)); err != nil {
+		t.Errorf("Lenient ParseIntegrityBlock should accept non-canonical attributes. err: %v", err)
+	}
+
+	_, err := ParseIntegrityBlockWithOptions(bytes.NewReader(integrityBlockBytes), ParseOptions{Strict: true})
+	if err == nil {
+		t.Fatal("Strict parsing should fail with non-canonical attributes.")
+	}
+	if !strings.Contains(err.Error(), `"a"`) {
+		t.Errorf("integrityblock: error should identify the offending key, got: %v", err)
+	}
+}
+
+func TestParseIntegrityBlockStrictlyWithCanonicalAttributes(t *testing.T) {
+	integrityBlock := generateEmptyIntegrityBlock()
+	integrityBlock.addNewSignatureToIntegrityBlock(SignatureAttributesMap{Ed25519publicKeyAttributeName: []byte("publickey"), "hello": []byte("world"), "a": []byte("b")}, []byte("signature"))
+
+	integrityBlockBytes, err := integrityBlock.CborBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ParseIntegrityBlockWithOptions(bytes.NewReader(integrityBlockBytes), ParseOptions{Strict: true}); err != nil {
+		t.Errorf("Strict parsing should accept an integrity block encoded by this package. err: %v", err)
+	}
+}
+
+func TestParseIntegrityBlockPreservesUnknownAttributes(t *testing.T) {
+	// ["🖋📦" "1b\x00\x00" [[map["futureAttribute":"value" "ed25519PublicKey":"publickey" "a":"b"] "signature"]]],
+	// where the keys are not in canonical order.
+	integrityBlockBytes := []byte{0x83, 0x48}
+	integrityBlockBytes = append(integrityBlockBytes, IntegrityBlockMagic...)
+	integrityBlockBytes = append(integrityBlockBytes, 0x44)
+	integrityBlockBytes = append(integrityBlockBytes, VersionB1...)
+	integrityBlockBytes = append(integrityBlockBytes, 0x81, 0x82, 0xa3)
+	integrityBlockBytes = append(integrityBlockBytes, 0x6f)
+	integrityBlockBytes = append(integrityBlockBytes, "futureAttribute"...)
+	integrityBlockBytes = append(integrityBlockBytes, 0x45)
+	integrityBlockBytes = append(integrityBlockBytes, "value"...)
+	integrityBlockBytes = append(integrityBlockBytes, 0x70)
+	integrityBlockBytes = append(integrityBlockBytes, Ed25519publicKeyAttributeName...)
+	integrityBlockBytes = append(integrityBlockBytes, 0x49)
+	integrityBlockBytes = append(integrityBlockBytes, "publickey"...)
+	integrityBlockBytes = append(integrityBlockBytes, 0x61, 'a', 0x41, 'b')
+	integrityBlockBytes = append(integrityBlockBytes, 0x49, 's', 'i', 'g', 'n', 'a', 't', 'u', 'r', 'e')
+
+	got, err := ParseIntegrityBlock(bytes.NewReader(integrityBlockBytes))
+	if err != nil {
+		t.Fatalf("ParseIntegrityBlock. err: %v", err)
+	}
+	if value, ok := got.SignatureStack[0].Attribute("futureAttribute"); !ok || string(value) != "value" {
+		t.Errorf("integrityblock: got unknown attribute: %q, %v\nwant: \"value\", true", value, ok)
+	}
+
+	want := generateEmptyIntegrityBlock()
+	want.addNewSignatureToIntegrityBlock(SignatureAttributesMap{
+		"a":                           []byte("b"),
+		"futureAttribute":             []byte("value"),
+		Ed25519publicKeyAttributeName: []byte("publickey"),
+	}, []byte("signature"))
+	wantBytes, err := want.CborBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Re-encoding keeps the unknown attribute and puts the keys in the canonical order.
+	reencodedBytes, err := got.CborBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(reencodedBytes, wantBytes) {
+		t.Errorf("integrityblock: got: %x\nwant: %x", reencodedBytes, wantBytes)
+	}
+	if _, err := ParseIntegrityBlockWithOptions(bytes.NewReader(reencodedBytes), ParseOptions{Strict: true}); err != nil {
+		t.Errorf("Re-encoded integrity block should be canonical. err: %v", err)
+	}
+}
+
+func TestVerifyCanonicalEncoding(t *testing.T) {
+	integrityBlock := generateEmptyIntegrityBlock()
+	integrityBlock.addNewSignatureToIntegrityBlock(SignatureAttributesMap{Ed25519publicKeyAttributeName: []byte("publickey"), "a": []byte("1")}, []byte("signature"))
+	integrityBlockBytes, err := integrityBlock.CborBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signedBundle := append(bytes.Clone(integrityBlockBytes), []byte("webbundle")...)
+	if err := VerifyCanonicalEncoding(bytes.NewReader(signedBundle)); err != nil {
+		t.Errorf("VerifyCanonicalEncoding. err: %v", err)
+	}
+
+	// The magic with its length encoded in a non-minimal form as 0x58 0x08.
+	nonMinimalLength := append([]byte{0x83, 0x58, 0x08}, integrityBlockBytes[2:]...)
+
+	// {"ed25519PublicKey": ..., "a": ...}, the keys in the reverse of the canonical order.
+	nonCanonicalOrder := []byte{0x83, 0x48}
+	nonCanonicalOrder = append(nonCanonicalOrder, IntegrityBlockMagic...)
+	nonCanonicalOrder = append(nonCanonicalOrder, 0x44)
+	nonCanonicalOrder = append(nonCanonicalOrder, VersionB1...)
+	nonCanonicalOrder = append(nonCanonicalOrder, 0x81, 0x82, 0xa2, 0x70)
+	nonCanonicalOrder = append(nonCanonicalOrder, Ed25519publicKeyAttributeName...)
+	nonCanonicalOrder = append(nonCanonicalOrder, 0x41, 'k', 0x61, 'a', 0x41, '1', 0x40)
+
+	for name, input := range map[string][]byte{"non-minimal length": nonMinimalLength, "non-canonical order": nonCanonicalOrder} {
+		if err := VerifyCanonicalEncoding(bytes.NewReader(input)); !errors.Is(err, ErrNonCanonicalEncoding) {
+			t.Errorf("%s: got err: %v\nwant: %v", name, err, ErrNonCanonicalEncoding)
+		}
+	}
+
+	if err := VerifyCanonicalEncoding(bytes.NewReader([]byte("webbundle"))); err == nil || errors.Is(err, ErrNonCanonicalEncoding) {
+		t.Errorf("integrityblock: got err: %v, want a parsing error", err)
+	}
+}
+
+func TestParseIntegrityBlockStrict(t *testing.T) {
+	webBundleHash := make([]byte, 64)
+	integrityBlock, err := NewSignedIntegrityBlock(webBundleHash, NewParsedEd25519KeySigningStrategy(ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	integrityBlock.SignatureStack[0].SignatureAttributes.SetDate(time.Unix(0, 0))
+	integrityBlockBytes, err := integrityBlock.CborBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := ParseIntegrityBlockStrict(bytes.NewReader(integrityBlockBytes))
+	if err != nil {
+		t.Fatalf("ParseIntegrityBlockStrict. err: %v", err)
+	}
+	if parsed.DiagnosticString() != integrityBlock.DiagnosticString() {
+		t.Errorf("integrityblock: got: %s\nwant: %s", parsed.DiagnosticString(), integrityBlock.DiagnosticString())
+	}
+
+	withUnknownAttribute := integrityBlock.Clone()
+	withUnknownAttribute.SignatureStack[0].SignatureAttributes["futureAttribute"] = []byte("value")
+	withUnknownAttributeBytes, err := withUnknownAttribute.CborBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	withShortSignature := integrityBlock.Clone()
+	withShortSignature.SignatureStack[0].Signature = []byte("signature")
+	withShortSignatureBytes, err := withShortSignature.CborBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The magic with its length encoded in a non-minimal form as 0x58 0x08.
+	nonMinimalLength := append([]byte{0x83, 0x58, 0x08}, integrityBlockBytes[2:]...)
+
+	tests := []struct {
+		name       string
+		input      []byte
+		wantSubstr string
+	}{
+		{"unknown attribute", withUnknownAttributeBytes, `Unknown signature attribute "futureAttribute"`},
+		{"short signature", withShortSignatureBytes, "should be 64 bytes"},
+		{"non-minimal length", nonMinimalLength, "not canonically encoded"},
+	}
+	for _, test := range tests {
+		// The lenient parser accepts all of these.
+		if _, err := ParseIntegrityBlock(bytes.NewReader(test.input)); err != nil {
+			t.Errorf("%s: ParseIntegrityBlock. err: %v", test.name, err)
+		}
+		_, err := ParseIntegrityBlockStrict(bytes.NewReader(test.input))
+		if err == nil || !strings.Contains(err.Error(), test.wantSubstr) {
+			t.Errorf("%s: got err: %v, want an error containing %q", test.name, err, test.wantSubstr)
+		}
+	}
+}
+
+func TestParseIntegrityBlockWithHugeSignatureStackLength(t *testing.T) {
+	// ["🖋📦" "1b\x00\x00" [...]] declaring 2^64-1 signatures.
+	integrityBlockBytes := []byte{0x83, 0x48}
+	integrityBlockBytes = append(integrityBlockBytes, IntegrityBlockMagic...)
+	integrityBlockBytes = append(integrityBlockBytes, 0x44)
+	integrityBlockBytes = append(integrityBlockBytes, VersionB1...)
+	integrityBlockBytes = append(integrityBlockBytes, 0x9b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff)
+
+	// The reader hides the remaining length, so only the preallocation cap protects the parser.
+	if _, err := ParseIntegrityBlock(io.MultiReader(bytes.NewReader(integrityBlockBytes))); err == nil {
+		t.Error("ParseIntegrityBlock should fail with a truncated signature stack.")
+	}
+	if _, err := ParseIntegrityBlock(bytes.NewReader(integrityBlockBytes)); err == nil {
+		t.Error("ParseIntegrityBlock should fail when the signature stack length exceeds the input.")
+	}
+}
+
+func FuzzParseIntegrityBlock(f *testing.F) {
+	emptyIntegrityBlockBytes, err := generateEmptyIntegrityBlock().CborBytes()
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(emptyIntegrityBlockBytes)
+
+	signedIntegrityBlock := generateEmptyIntegrityBlock()
+	signedIntegrityBlock.addNewSignatureToIntegrityBlock(SignatureAttributesMap{Ed25519publicKeyAttributeName: bytes.Repeat([]byte{0x01}, Ed25519PublicKeySize)}, bytes.Repeat([]byte{0x02}, Ed25519SignatureSize))
+	signedIntegrityBlockBytes, err := signedIntegrityBlock.CborBytes()
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(signedIntegrityBlockBytes)
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		r := bytes.NewReader(data)
+		integrityBlock, err := ParseIntegrityBlock(r)
+		if err != nil {
+			return
+		}
+
+		// The parser accepts e.g. non-minimal lengths, which re-encode differently, but an integrity block
+		// parsed from deterministic CBOR must re-encode into exactly the bytes it was parsed from.
+		parsedBytes := data[:len(data)-r.Len()]
+		if err := cbor.Deterministic(parsedBytes); err != nil {
+			return
+		}
+		reencodedBytes, err := integrityBlock.CborBytes()
+		if err != nil {
+			t.Fatalf("CborBytes of a parsed integrity block. err: %v", err)
+		}
+		if !bytes.Equal(reencodedBytes, parsedBytes) {
+			t.Errorf("integrityblock: got: %x\nwant: %x", reencodedBytes, parsedBytes)
+		}
+	})
+}
+
+func TestPeekVersion(t *testing.T) {
+	integrityBlock := generateEmptyIntegrityBlock()
+	integrityBlock.Version = []byte{0x39, 0x39, 0x00, 0x00}
+	integrityBlockBytes, err := integrityBlock.CborBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Everything after the version is cut off, as it must not be needed.
+	headerSize := 1 + 1 + integrityBlockMagicSize + 1 + integrityBlockVersionSize
+	r := bytes.NewReader(append(integrityBlockBytes[:headerSize:headerSize], "rest"...))
+	got, err := PeekVersion(r)
+	if err != nil {
+		t.Fatalf("PeekVersion. err: %v", err)
+	}
+	if !bytes.Equal(got, integrityBlock.Version) {
+		t.Errorf("integrityblock: got: %x\nwant: %x", got, integrityBlock.Version)
+	}
+	if r.Len() != len("rest") {
+		t.Errorf("integrityblock: PeekVersion should stop after the version, got %d bytes left", r.Len())
+	}
+
+	integrityBlock.Magic = []byte("notmagic")
+	integrityBlockBytes, err = integrityBlock.CborBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := PeekVersion(bytes.NewReader(integrityBlockBytes)); err == nil {
+		t.Error("PeekVersion should fail with an invalid magic.")
+	}
+	if _, err := PeekVersion(bytes.NewReader([]byte{0x82, 0x40, 0x40})); !errors.Is(err, ErrUnexpectedIntegrityBlockSize) {
+		t.Errorf("integrityblock: got err: %v\nwant: %v", err, ErrUnexpectedIntegrityBlockSize)
+	}
+}
+
+func TestParseIntegrityBlockStrictWithHugeDeclaredLength(t *testing.T) {
+	integrityBlockBytes := []byte{0x83, 0x48}
+	integrityBlockBytes = append(integrityBlockBytes, IntegrityBlockMagic...)
+	integrityBlockBytes = append(integrityBlockBytes, 0x44)
+	integrityBlockBytes = append(integrityBlockBytes, VersionB1...)
+	// A signature stack of one signature whose public key attribute declares a 4 GiB value.
+	integrityBlockBytes = append(integrityBlockBytes, 0x81, 0x82, 0xa1, 0x70)
+	integrityBlockBytes = append(integrityBlockBytes, Ed25519publicKeyAttributeName...)
+	integrityBlockBytes = append(integrityBlockBytes, 0x5a, 0xff, 0xff, 0xff, 0xff, 0x00)
+
+	// The file doesn't tell its remaining length through the io.TeeReader used for the canonical check.
+	bundleFile := createTempFileHelper(t, integrityBlockBytes)
+	defer bundleFile.Close()
+	if _, err := ParseIntegrityBlockStrict(bundleFile); err == nil || !strings.Contains(err.Error(), "Declared length") {
+		t.Errorf("integrityblock: got err: %v, want a declared length error", err)
+	}
+}
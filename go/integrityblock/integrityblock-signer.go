@@ -1,8 +1,11 @@
 package integrityblock
 
 import (
+	"context"
 	"crypto/ed25519"
+	"crypto/sha512"
 	"errors"
+	"fmt"
 
 	"github.com/WICG/webpackage/go/internal/cbor"
 )
@@ -13,6 +16,160 @@ type IntegrityBlockSigner struct {
 	IntegrityBlock  *IntegrityBlock
 }
 
+// GenerateSignedPayload returns the exact bytes which get signed when a new signature with the given
+// signature attributes is added on top of the integrity block's signature stack. It does not sign
+// anything, so the payload can be signed externally, e.g. with an HSM. The payload is the concatenation
+// of the following, where the lengths are 64 bit big-endian integers:
+//  1. length of the web bundle hash,
+//  2. the web bundle hash (SHA-512 of the web bundle bytes not including the integrity block),
+//  3. length of the serialized integrity block,
+//  4. the integrity block serialized as deterministic CBOR in its current state, meaning without the
+//     signature about to be created,
+//  5. length of the serialized signature attributes,
+//  6. the signature attributes serialized as deterministic CBOR.
+//
+// The serialized integrity block includes the magic and the version, so the payload is bound to the integrity
+// block version. This is the payload definition of the "1b" draft of the integrity block, as implemented by
+// the wbn-sign tool in js/sign, which the test vectors in integrityblock-signer_test.go were produced with.
+// See `GenerateSignedPayloadWithOptions` for the variants used in cross-implementation testing.
+//
+// A verifier reconstructs the same payload after popping the signature from the signature stack.
+func GenerateSignedPayload(ib *IntegrityBlock, signatureAttributes SignatureAttributesMap, webBundleHash []byte) ([]byte, error) {
+	return GenerateSignedPayloadWithOptions(ib, signatureAttributes, webBundleHash, SignedPayloadOptions{})
+}
+
+// SignedPayloadOptions controls what `GenerateSignedPayloadWithOptions` includes in the signed payload. The
+// zero value is the payload definition of the "1b" draft used by `GenerateSignedPayload`.
+type SignedPayloadOptions struct {
+	// OmitMagicAndVersion makes the integrity block element of the payload contain only the CBOR encoded
+	// signature stack instead of the whole integrity block array, for testing interop with implementations
+	// which don't bind the payload to the magic and the version. Signatures over such a payload don't verify
+	// with `VerifyIntegrityBlock`.
+	OmitMagicAndVersion bool
+}
+
+// GenerateSignedPayloadWithOptions is like `GenerateSignedPayload`, but the contents of the payload are
+// controlled by `opts`.
+func GenerateSignedPayloadWithOptions(ib *IntegrityBlock, signatureAttributes SignatureAttributesMap, webBundleHash []byte, opts SignedPayloadOptions) ([]byte, error) {
+	var integrityBlockBytes []byte
+	var err error
+	if opts.OmitMagicAndVersion {
+		integrityBlockBytes, err = ib.signatureStackCborBytes()
+	} else {
+		integrityBlockBytes, err = ib.CborBytes()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Ensure the CBOR on the integrity block follows the deterministic principles.
+	err = cbor.Deterministic(integrityBlockBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	return GenerateDataToBeSigned(webBundleHash, integrityBlockBytes, signatureAttributes)
+}
+
+// SignIntegrityBlock creates a new Ed25519 integrity signature over the given integrity block and web bundle hash
+// using the given signer. The signature attributes contain the public key of the signer. The integrity block is not
+// modified; the returned signature is expected to be prepended to the signature stack of the same integrity block.
+func SignIntegrityBlock(ib *IntegrityBlock, signer Signer, webBundleHash []byte) (*IntegritySignature, error) {
+	return SignIntegrityBlockWithAttributes(ib, signer, webBundleHash, nil)
+}
+
+// SignIntegrityBlockWithAttributes is like `SignIntegrityBlock`, but the signature attributes contain the given
+// additional attributes, e.g. the date attribute set with `SetDate`, besides the public key of the signer. The
+// additional attributes are signed like the public key, and they cannot contain a public key attribute.
+func SignIntegrityBlockWithAttributes(ib *IntegrityBlock, signer Signer, webBundleHash []byte, additionalAttributes SignatureAttributesMap) (*IntegritySignature, error) {
+	return signIntegrityBlock(ib, signer.PublicKey(), signer.Sign, webBundleHash, additionalAttributes)
+}
+
+// SignIntegrityBlockContext is like `SignIntegrityBlock`, but the signer gets the context, so that signing with
+// e.g. a cloud KMS respects the cancellation and the deadline of `ctx`. It returns ctx.Err() without signing if
+// the context is already done. Use `NewContextSigner` for signers which don't need the context.
+func SignIntegrityBlockContext(ctx context.Context, ib *IntegrityBlock, signer ContextSigner, webBundleHash []byte) (*IntegritySignature, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	sign := func(payload []byte) ([]byte, error) {
+		return signer.Sign(ctx, payload)
+	}
+	return signIntegrityBlock(ib, signer.PublicKey(), sign, webBundleHash, nil)
+}
+
+// signIntegrityBlock creates the integrity signature of `SignIntegrityBlockWithAttributes` signing the payload
+// with `sign`, which must create a signature verifiable with `publicKey`.
+func signIntegrityBlock(ib *IntegrityBlock, publicKey ed25519.PublicKey, sign func(payload []byte) ([]byte, error), webBundleHash []byte, additionalAttributes SignatureAttributesMap) (*IntegritySignature, error) {
+	if len(publicKey) != Ed25519PublicKeySize {
+		return nil, errors.New("integrityblock: Invalid Ed25519 public key length.")
+	}
+	signatureAttributes := GenerateSignatureAttributesWithPublicKey(publicKey)
+	for name, value := range additionalAttributes {
+		if isPublicKeyAttributeName(name) {
+			return nil, fmt.Errorf("integrityblock: Additional signature attributes cannot contain the %s attribute.", name)
+		}
+		signatureAttributes[name] = value
+	}
+
+	dataToBeSigned, err := GenerateSignedPayload(ib, signatureAttributes, webBundleHash)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := sign(dataToBeSigned)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := VerifyEd25519Signature(publicKey, signature, dataToBeSigned); err != nil {
+		return nil, err
+	}
+
+	return &IntegritySignature{
+		SignatureAttributes: signatureAttributes,
+		Signature:           signature,
+	}, nil
+}
+
+// SignPrecomputedHash creates a new Ed25519 integrity signature over an empty integrity block given only the
+// SHA-512 hash of the web bundle, so that hashing and signing can happen in separate services. To sign on top of
+// an existing integrity block, use `SignIntegrityBlock`. It fails if the hash is not a SHA-512 digest.
+func SignPrecomputedHash(webBundleHash []byte, signer Signer) (*IntegritySignature, error) {
+	if len(webBundleHash) != sha512.Size {
+		return nil, fmt.Errorf("integrityblock: Web bundle hash should be a %d byte SHA-512 digest, got %d bytes.", sha512.Size, len(webBundleHash))
+	}
+	return SignIntegrityBlock(generateEmptyIntegrityBlock(), signer, webBundleHash)
+}
+
+// NewSignedIntegrityBlock creates a new integrity block whose signature stack contains a single signature by the
+// given signer over the web bundle hash. The returned integrity block is ready to be serialized with `CborBytes`.
+func NewSignedIntegrityBlock(webBundleHash []byte, signer Signer) (*IntegrityBlock, error) {
+	integrityBlock := generateEmptyIntegrityBlock()
+	integritySignature, err := SignIntegrityBlock(integrityBlock, signer, webBundleHash)
+	if err != nil {
+		return nil, err
+	}
+	if err := AppendSignature(integrityBlock, integritySignature); err != nil {
+		return nil, err
+	}
+	return integrityBlock, nil
+}
+
+// checkSignerPublicKeyAttribute ensures that the signature attributes contain the Ed25519 public key attribute and
+// that it is the public key of the signer. Without this check a signature could be created with one key while the
+// attributes advertise another, which no verifier would ever accept.
+func checkSignerPublicKeyAttribute(signatureAttributes SignatureAttributesMap, publicKey ed25519.PublicKey) error {
+	attributeKey, ok := signatureAttributes[Ed25519publicKeyAttributeName]
+	if !ok {
+		return fmt.Errorf("integrityblock: Signature attributes are missing the %s attribute.", Ed25519publicKeyAttributeName)
+	}
+	if !publicKeysEqual(attributeKey, publicKey) {
+		return fmt.Errorf("integrityblock: The %s attribute doesn't match the signer's public key.", Ed25519publicKeyAttributeName)
+	}
+	return nil
+}
+
 // VerifyEd25519Signature verifies that the given signature can be verified with the given public key and matches the data signed.
 func VerifyEd25519Signature(publicKey ed25519.PublicKey, signature, dataToBeSigned []byte) (bool, error) {
 	signatureOk := ed25519.Verify(publicKey, dataToBeSigned, signature)
@@ -25,18 +182,11 @@ func VerifyEd25519Signature(publicKey ed25519.PublicKey, signature, dataToBeSign
 // SignAndAddNewSignature contains the main logic for generating the new signature and
 // prepending the integrity block's signature stack with a new integrity signature object.
 func (ibs *IntegrityBlockSigner) SignAndAddNewSignature(ed25519publicKey ed25519.PublicKey, signatureAttributes SignatureAttributesMap) error {
-	integrityBlockBytes, err := ibs.IntegrityBlock.CborBytes()
-	if err != nil {
-		return err
-	}
-
-	// Ensure the CBOR on the integrity block follows the deterministic principles.
-	err = cbor.Deterministic(integrityBlockBytes)
-	if err != nil {
+	if err := checkSignerPublicKeyAttribute(signatureAttributes, ed25519publicKey); err != nil {
 		return err
 	}
 
-	dataToBeSigned, err := GenerateDataToBeSigned(ibs.WebBundleHash, integrityBlockBytes, signatureAttributes)
+	dataToBeSigned, err := GenerateSignedPayload(ibs.IntegrityBlock, signatureAttributes, ibs.WebBundleHash)
 	if err != nil {
 		return err
 	}
@@ -48,7 +198,9 @@ func (ibs *IntegrityBlockSigner) SignAndAddNewSignature(ed25519publicKey ed25519
 
 	// Verification is done after signing to ensure that the signing was successful and that the obtained public key
 	// is not corrupted and corresponds to the private key used for signing.
-	VerifyEd25519Signature(ed25519publicKey, signature, dataToBeSigned)
+	if _, err := VerifyEd25519Signature(ed25519publicKey, signature, dataToBeSigned); err != nil {
+		return err
+	}
 
 	ibs.IntegrityBlock.addNewSignatureToIntegrityBlock(signatureAttributes, signature)
 	return nil
@@ -0,0 +1,135 @@
+package integrityblock
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// signedPayloadTestVectors are the signed payloads and Ed25519 signatures when signing the web bundle hash
+// 0x00..0x3f with the key `testEd25519PrivateKeyPEM`. They were produced with the reference implementation in
+// js/sign: the payloads by `IntegrityBlockSigner.generateDataToBeSigned` over `IntegrityBlock.toCBOR`, and the
+// signatures by Node.js's crypto.sign. The payload is split into the framed elements listed in the
+// documentation of `GenerateSignedPayload`. As Ed25519 signatures are deterministic, any implementation of
+// the same payload definition must produce byte-identical signatures.
+var signedPayloadTestVectors = []struct {
+	name          string
+	numSignatures int
+	payload       []string
+	signature     string
+}{
+	{
+		name:          "First signature",
+		numSignatures: 0,
+		payload: []string{
+			// Length and the web bundle hash.
+			"0000000000000040",
+			"000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f202122232425262728292a2b2c2d2e2f303132333435363738393a3b3c3d3e3f",
+			// Length and the integrity block ["🖋📦" "1b\x00\x00" []].
+			"0000000000000010",
+			"8348f09f968bf09f93a6443162000080",
+			// Length and the signature attributes map["ed25519PublicKey":publickey].
+			"0000000000000034",
+			"a170656432353531395075626c69634b65795820e4d516c9859af86356a351667dbd004361101a92d40272fe2bce81bb3b713f2d",
+		},
+		signature: "43ce6efa638c7ace00e1b1b1fc54bc74a3c1cf7de37f7ad21d5f014f8e3278e0051d1ecb9db0592d643cfde13470e387b14baca121a98c9fb5ae0e7f331c420a",
+	},
+	{
+		name:          "Second signature by the same key",
+		numSignatures: 1,
+		payload: []string{
+			"0000000000000040",
+			"000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f202122232425262728292a2b2c2d2e2f303132333435363738393a3b3c3d3e3f",
+			// The integrity block now contains the first signature.
+			"0000000000000087",
+			"8348f09f968bf09f93a644316200008182" +
+				"a170656432353531395075626c69634b65795820e4d516c9859af86356a351667dbd004361101a92d40272fe2bce81bb3b713f2d" +
+				"584043ce6efa638c7ace00e1b1b1fc54bc74a3c1cf7de37f7ad21d5f014f8e3278e0051d1ecb9db0592d643cfde13470e387b14baca121a98c9fb5ae0e7f331c420a",
+			"0000000000000034",
+			"a170656432353531395075626c69634b65795820e4d516c9859af86356a351667dbd004361101a92d40272fe2bce81bb3b713f2d",
+		},
+		signature: "548ee97607fb1a3668284eb91f87c127c0a8515e4532ff83fe75a0b2c95c1b23eed5700b8b333a78a3199cc3c6a006d4c17c695e0952cc7d080c90038c7ff409",
+	},
+}
+
+func TestSignedPayloadTestVectors(t *testing.T) {
+	privateKey, err := ParseEd25519PrivateKeyFromPEM([]byte(testEd25519PrivateKeyPEM))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := NewParsedEd25519KeySigningStrategy(privateKey)
+	webBundleHash := make([]byte, 64)
+	for i := range webBundleHash {
+		webBundleHash[i] = byte(i)
+	}
+
+	for _, tv := range signedPayloadTestVectors {
+		t.Run(tv.name, func(t *testing.T) {
+			integrityBlock := generateEmptyIntegrityBlock()
+			for i := 0; i < tv.numSignatures; i++ {
+				integritySignature, err := SignIntegrityBlock(integrityBlock, signer, webBundleHash)
+				if err != nil {
+					t.Fatal(err)
+				}
+				AppendSignature(integrityBlock, integritySignature)
+			}
+
+			payload, err := GenerateSignedPayload(integrityBlock, GenerateSignatureAttributesWithPublicKey(signer.PublicKey()), webBundleHash)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := hex.EncodeToString(payload), strings.Join(tv.payload, ""); got != want {
+				t.Errorf("integrityblock: got payload: %s\nwant: %s", got, want)
+			}
+
+			integritySignature, err := SignIntegrityBlock(integrityBlock, signer, webBundleHash)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := hex.EncodeToString(integritySignature.Signature); got != tv.signature {
+				t.Errorf("integrityblock: got signature: %s\nwant: %s", got, tv.signature)
+			}
+
+			signature, err := hex.DecodeString(tv.signature)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !ed25519.Verify(signer.PublicKey(), payload, signature) {
+				t.Error("Test vector signature should verify over the test vector payload.")
+			}
+		})
+	}
+}
+
+func TestGenerateSignedPayloadWithoutMagicAndVersion(t *testing.T) {
+	integrityBlock := generateEmptyIntegrityBlock()
+	signatureAttributes := SignatureAttributesMap{Ed25519publicKeyAttributeName: make([]byte, Ed25519PublicKeySize)}
+	webBundleHash := make([]byte, 64)
+
+	got, err := GenerateSignedPayloadWithOptions(integrityBlock, signatureAttributes, webBundleHash, SignedPayloadOptions{OmitMagicAndVersion: true})
+	if err != nil {
+		t.Fatalf("GenerateSignedPayloadWithOptions. err: %v", err)
+	}
+	// The integrity block element is only the empty signature stack array.
+	want, err := GenerateDataToBeSigned(webBundleHash, []byte{0x80}, signatureAttributes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("integrityblock: got: %x\nwant: %x", got, want)
+	}
+
+	withMagicAndVersion, err := GenerateSignedPayloadWithOptions(integrityBlock, signatureAttributes, webBundleHash, SignedPayloadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaultPayload, err := GenerateSignedPayload(integrityBlock, signatureAttributes, webBundleHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(withMagicAndVersion, defaultPayload) {
+		t.Error("Zero SignedPayloadOptions should produce the payload of GenerateSignedPayload.")
+	}
+}
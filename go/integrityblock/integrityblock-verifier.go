@@ -0,0 +1,341 @@
+package integrityblock
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+var (
+	ErrInvalidSignature = errors.New("integrityblock: Integrity signature is not valid.")
+	ErrSignerNotAllowed = errors.New("integrityblock: None of the valid integrity signatures is from an allowed signer.")
+	ErrDuplicateSigner  = errors.New("integrityblock: Signature stack contains multiple signatures by the same public key.")
+	ErrNoSignatures     = errors.New("integrityblock: Signature stack is empty.")
+	ErrHashMismatch     = errors.New("integrityblock: Web bundle hash doesn't match the expected hash.")
+)
+
+// VerifyIntegritySignature verifies the given Ed25519 integrity signature against the web bundle hash. The
+// integrity block must be in the state the signature was created over, meaning without the signature itself
+// and without any signatures added after it. The returned boolean is false with a nil error if the signature
+// is well-formed but doesn't verify, and an error is returned if the signature or its attributes are malformed.
+func VerifyIntegritySignature(ib *IntegrityBlock, is *IntegritySignature, webBundleHash []byte) (bool, error) {
+	publicKey, err := ed25519PublicKeyFromAttributes(is.SignatureAttributes)
+	if err != nil {
+		return false, err
+	}
+	if len(is.Signature) != Ed25519SignatureSize {
+		return false, fmt.Errorf("integrityblock: Ed25519 signature should be %d bytes, got %d bytes.", Ed25519SignatureSize, len(is.Signature))
+	}
+
+	dataToBeSigned, err := GenerateSignedPayload(ib, is.SignatureAttributes, webBundleHash)
+	if err != nil {
+		return false, err
+	}
+
+	return ed25519.Verify(publicKey, dataToBeSigned, is.Signature), nil
+}
+
+// SignatureVerificationResult is the outcome of verifying one integrity signature on the signature stack.
+type SignatureVerificationResult struct {
+	// Index is the position of the signature on the signature stack.
+	Index int
+	// PublicKey is the value of the public key attribute of the signature, or nil if the signature
+	// attributes don't contain exactly one public key attribute.
+	PublicKey []byte
+	// Signature is the verified integrity signature.
+	Signature *IntegritySignature
+	// Valid tells whether the signature verified.
+	Valid bool
+	// Err describes why the signature is not valid. It is `ErrInvalidSignature` if the signature is
+	// well-formed but doesn't verify.
+	Err error
+}
+
+// VerificationResult is the outcome of verifying all the integrity signatures on the signature stack.
+type VerificationResult struct {
+	// Valid is true if all the signatures on the signature stack are valid.
+	Valid bool
+	// Signatures contains the result of every signature in the signature stack's order.
+	Signatures []SignatureVerificationResult
+}
+
+// ValidSignatures returns the valid integrity signatures in the signature stack's order.
+func (vr *VerificationResult) ValidSignatures() []*IntegritySignature {
+	var valid []*IntegritySignature
+	for _, signatureResult := range vr.Signatures {
+		if signatureResult.Valid {
+			valid = append(valid, signatureResult.Signature)
+		}
+	}
+	return valid
+}
+
+// InvalidSignatures returns the invalid integrity signatures in the signature stack's order.
+func (vr *VerificationResult) InvalidSignatures() []*IntegritySignature {
+	var invalid []*IntegritySignature
+	for _, signatureResult := range vr.Signatures {
+		if !signatureResult.Valid {
+			invalid = append(invalid, signatureResult.Signature)
+		}
+	}
+	return invalid
+}
+
+// VerifyIntegrityBlock verifies every signature on the signature stack of the given integrity block and
+// returns the result of each of them. The newest signature is the first one on the stack, so the signature
+// at index i is verified against the integrity block containing only the signatures after it. An invalid
+// signature doesn't cause an error; it is reported in the returned result instead. An empty signature stack
+// fails with `ErrNoSignatures`, as an integrity block without signatures doesn't vouch for anything.
+func VerifyIntegrityBlock(ib *IntegrityBlock, webBundleHash []byte) (*VerificationResult, error) {
+	if ib == nil {
+		return nil, errors.New("integrityblock: Cannot verify a nil integrity block.")
+	}
+	if len(ib.SignatureStack) == 0 {
+		return nil, ErrNoSignatures
+	}
+
+	result := &VerificationResult{
+		Valid:      true,
+		Signatures: make([]SignatureVerificationResult, 0, len(ib.SignatureStack)),
+	}
+	for i, integritySignature := range ib.SignatureStack {
+		signatureResult := SignatureVerificationResult{Index: i, Signature: integritySignature}
+		if integritySignature != nil {
+			if attributeName, err := integritySignature.SignatureAttributes.publicKeyAttributeName(); err == nil {
+				signatureResult.PublicKey = integritySignature.SignatureAttributes[attributeName]
+			}
+		}
+
+		ok, err := verifyIntegritySignatureOfAnyAlgorithm(ib.withSignatureStack(ib.SignatureStack[i+1:]), integritySignature, webBundleHash)
+		switch {
+		case err != nil:
+			signatureResult.Err = err
+		case !ok:
+			signatureResult.Err = ErrInvalidSignature
+		default:
+			signatureResult.Valid = true
+		}
+
+		result.Valid = result.Valid && signatureResult.Valid
+		result.Signatures = append(result.Signatures, signatureResult)
+	}
+	return result, nil
+}
+
+// verifyIntegritySignatureOfAnyAlgorithm verifies the integrity signature with the algorithm identified by
+// the public key attribute present in its signature attributes.
+func verifyIntegritySignatureOfAnyAlgorithm(ib *IntegrityBlock, is *IntegritySignature, webBundleHash []byte) (bool, error) {
+	if is == nil {
+		return false, errors.New("integrityblock: Integrity signature is nil.")
+	}
+	attributeName, err := is.SignatureAttributes.publicKeyAttributeName()
+	if err != nil {
+		return false, err
+	}
+
+	// Every registered public key attribute has a `Verify` function.
+	return attributeRegistry[attributeName].Verify(ib, is, webBundleHash)
+}
+
+// SignedBytesForIndex reconstructs the payload which the signature at the given index of the signature stack
+// covers, e.g. to find out why that signature of a multi-signature integrity block fails the verification. The
+// newest signature is the first one on the stack and every signature covers the integrity block as it was when
+// the signature was created, so the payload of the signature at `index` is built from the integrity block
+// containing only the signatures after it, SignatureStack[index+1:], and the signature's own attributes.
+func SignedBytesForIndex(ib *IntegrityBlock, index int, webBundleHash []byte) ([]byte, error) {
+	if ib == nil {
+		return nil, errors.New("integrityblock: Cannot reconstruct the payload of a nil integrity block.")
+	}
+	if index < 0 || index >= len(ib.SignatureStack) {
+		return nil, fmt.Errorf("integrityblock: Index %d is out of range for a signature stack of %d signatures.", index, len(ib.SignatureStack))
+	}
+	integritySignature := ib.SignatureStack[index]
+	if integritySignature == nil {
+		return nil, fmt.Errorf("integrityblock: signatureStack[%d] is nil.", index)
+	}
+	return GenerateSignedPayload(ib.withSignatureStack(ib.SignatureStack[index+1:]), integritySignature.SignatureAttributes, webBundleHash)
+}
+
+// withSignatureStack returns a shallow copy of the integrity block with the given signature stack.
+func (ib *IntegrityBlock) withSignatureStack(signatureStack []*IntegritySignature) *IntegrityBlock {
+	return &IntegrityBlock{
+		Magic:          ib.Magic,
+		Version:        ib.Version,
+		SignatureStack: signatureStack,
+	}
+}
+
+// SignerPublicKeys returns the Ed25519 public keys of all the signatures on the signature stack in the
+// stack's order. It fails if any signature is nil or doesn't have a valid Ed25519 public key attribute.
+func SignerPublicKeys(ib *IntegrityBlock) ([]ed25519.PublicKey, error) {
+	publicKeys := make([]ed25519.PublicKey, 0, len(ib.SignatureStack))
+	for i, integritySignature := range ib.SignatureStack {
+		if integritySignature == nil {
+			return nil, fmt.Errorf("integrityblock: signatureStack[%d] is nil.", i)
+		}
+		publicKey, err := ed25519PublicKeyFromAttributes(integritySignature.SignatureAttributes)
+		if err != nil {
+			return nil, fmt.Errorf("integrityblock: signatureStack[%d]: %v", i, err)
+		}
+		publicKeys = append(publicKeys, publicKey)
+	}
+	return publicKeys, nil
+}
+
+// SignerPublicKeysSkipUnknown is like `SignerPublicKeys`, but skips the signatures which are nil or don't have a
+// valid Ed25519 public key attribute, e.g. the ones created with other signing algorithms.
+func SignerPublicKeysSkipUnknown(ib *IntegrityBlock) []ed25519.PublicKey {
+	var publicKeys []ed25519.PublicKey
+	for _, integritySignature := range ib.SignatureStack {
+		if integritySignature == nil {
+			continue
+		}
+		if publicKey, err := ed25519PublicKeyFromAttributes(integritySignature.SignatureAttributes); err == nil {
+			publicKeys = append(publicKeys, publicKey)
+		}
+	}
+	return publicKeys
+}
+
+// ed25519PublicKeyFromAttributes returns the value of the Ed25519 public key attribute after checking its length.
+func ed25519PublicKeyFromAttributes(signatureAttributes SignatureAttributesMap) (ed25519.PublicKey, error) {
+	publicKey, ok := signatureAttributes[Ed25519publicKeyAttributeName]
+	if !ok {
+		return nil, fmt.Errorf("integrityblock: Signature attributes are missing the %q attribute.", Ed25519publicKeyAttributeName)
+	}
+	if len(publicKey) != Ed25519PublicKeySize {
+		return nil, fmt.Errorf("integrityblock: Ed25519 public key should be %d bytes, got %d bytes.", Ed25519PublicKeySize, len(publicKey))
+	}
+	return ed25519.PublicKey(publicKey), nil
+}
+
+// VerifyAgainstAllowlist verifies every signature on the signature stack and checks the result against the
+// allowed Ed25519 public keys with the policy of `checkAllowlist`. An empty signature stack fails with
+// `ErrNoSignatures`.
+func VerifyAgainstAllowlist(ib *IntegrityBlock, webBundleHash []byte, allowed []ed25519.PublicKey) error {
+	result, err := VerifyIntegrityBlock(ib, webBundleHash)
+	if err != nil {
+		return err
+	}
+	return checkAllowlist(result, allowed)
+}
+
+// checkAllowlist is the allowlist policy shared by `VerifyAgainstAllowlist` and `VerifyBundleFile`: it fails
+// with `ErrInvalidSignature` if any signature of the verification result is invalid, as an invalid signature
+// means that the integrity block was tampered with, and with `ErrSignerNotAllowed` if all signatures are valid
+// but none is from one of the allowed Ed25519 public keys.
+func checkAllowlist(result *VerificationResult, allowed []ed25519.PublicKey) error {
+	foundAllowedSigner := false
+	for _, signatureResult := range result.Signatures {
+		if !signatureResult.Valid {
+			if errors.Is(signatureResult.Err, ErrInvalidSignature) {
+				return fmt.Errorf("%w signatureStack[%d] failed the verification.", ErrInvalidSignature, signatureResult.Index)
+			}
+			return fmt.Errorf("%w signatureStack[%d]: %v", ErrInvalidSignature, signatureResult.Index, signatureResult.Err)
+		}
+
+		publicKey, err := ed25519PublicKeyFromAttributes(signatureResult.Signature.SignatureAttributes)
+		if err != nil {
+			// Signatures of other algorithms cannot match the Ed25519 allowlist.
+			continue
+		}
+		for _, allowedKey := range allowed {
+			if publicKeysEqual(publicKey, allowedKey) {
+				foundAllowedSigner = true
+				break
+			}
+		}
+	}
+
+	if !foundAllowedSigner {
+		return ErrSignerNotAllowed
+	}
+	return nil
+}
+
+// VerifyIntegrityBlockWithBase64Key is like `VerifyAgainstAllowlist` with a single allowed Ed25519 public key,
+// which is given as base64 as commonly found in deployment configs. Both the standard and the URL-safe base64
+// alphabets are accepted, with or without padding.
+func VerifyIntegrityBlockWithBase64Key(ib *IntegrityBlock, webBundleHash []byte, pubKeyB64 string) error {
+	publicKey, err := decodeBase64PublicKey(pubKeyB64)
+	if err != nil {
+		return err
+	}
+	return VerifyAgainstAllowlist(ib, webBundleHash, []ed25519.PublicKey{publicKey})
+}
+
+// decodeBase64PublicKey decodes the Ed25519 public key from any of the base64 variants and checks its length.
+func decodeBase64PublicKey(pubKeyB64 string) (ed25519.PublicKey, error) {
+	trimmed := strings.TrimRight(pubKeyB64, "=")
+	var publicKey []byte
+	var err error
+	if strings.ContainsAny(trimmed, "-_") {
+		publicKey, err = base64.RawURLEncoding.DecodeString(trimmed)
+	} else {
+		publicKey, err = base64.RawStdEncoding.DecodeString(trimmed)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("integrityblock: Failed to decode base64 public key: %v", err)
+	}
+	if len(publicKey) != Ed25519PublicKeySize {
+		return nil, fmt.Errorf("integrityblock: Ed25519 public key should be %d bytes, got %d bytes.", Ed25519PublicKeySize, len(publicKey))
+	}
+	return ed25519.PublicKey(publicKey), nil
+}
+
+// CheckUniqueSigners checks that no two signatures on the signature stack have the same public key, for the
+// policies where signing twice with the same key is an error. It fails with `ErrDuplicateSigner` identifying
+// the duplicate. Signatures without exactly one public key attribute are skipped, as `Validate` reports those.
+func CheckUniqueSigners(ib *IntegrityBlock) error {
+	if ib == nil {
+		return errors.New("integrityblock: Cannot check a nil integrity block.")
+	}
+
+	seenPublicKeys := make(map[string]int)
+	for i, integritySignature := range ib.SignatureStack {
+		if integritySignature == nil {
+			continue
+		}
+		attributeName, err := integritySignature.SignatureAttributes.publicKeyAttributeName()
+		if err != nil {
+			continue
+		}
+
+		// Keys of different algorithms never collide, as the attribute name is part of the map key.
+		publicKey := integritySignature.SignatureAttributes[attributeName]
+		key := attributeName + "\x00" + string(publicKey)
+		if firstIndex, seen := seenPublicKeys[key]; seen {
+			return fmt.Errorf("%w signatureStack[%d] and signatureStack[%d] have the same %q %x.", ErrDuplicateSigner, firstIndex, i, attributeName, publicKey)
+		}
+		seenPublicKeys[key] = i
+	}
+	return nil
+}
+
+// publicKeysEqual compares the public keys in constant time. Public keys are not secret, but comparisons on
+// the verification paths are kept constant time for consistency. Keys of different lengths are never equal.
+func publicKeysEqual(a, b []byte) bool {
+	return subtle.ConstantTimeCompare(a, b) == 1
+}
+
+// VerifyWebBundleHash computes the SHA-512 hash of the web bundle starting from `offset` and compares it
+// in constant time against the expected hash, e.g. one distributed out of band.
+func VerifyWebBundleHash(bundleFile io.ReadSeeker, offset int64, expected []byte) (bool, error) {
+	return VerifyWebBundleHashWithAlgorithm(bundleFile, offset, expected, crypto.SHA512)
+}
+
+// VerifyWebBundleHashWithAlgorithm is like `VerifyWebBundleHash`, but the web bundle is hashed with the given
+// hash algorithm, see `ComputeWebBundleHash`. It is meant for hashes distributed out of band; the integrity
+// signatures always sign the SHA-512 hash.
+func VerifyWebBundleHashWithAlgorithm(bundleFile io.ReadSeeker, offset int64, expected []byte, hash crypto.Hash) (bool, error) {
+	webBundleHash, err := ComputeWebBundleHash(bundleFile, offset, hash)
+	if err != nil {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare(webBundleHash, expected) == 1, nil
+}
@@ -0,0 +1,465 @@
+package integrityblock
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func generateSignedIntegrityBlockHelper(t *testing.T, webBundleHash []byte) (*IntegrityBlock, ed25519.PublicKey) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal("Failed to generate test keys")
+	}
+
+	integrityBlock := generateEmptyIntegrityBlock()
+	integritySignature, err := SignIntegrityBlock(integrityBlock, NewParsedEd25519KeySigningStrategy(priv), webBundleHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	integrityBlock.addNewSignatureToIntegrityBlock(integritySignature.SignatureAttributes, integritySignature.Signature)
+	return integrityBlock, pub
+}
+
+func sha512Helper(bts []byte) []byte {
+	hash := sha512.Sum512(bts)
+	return hash[:]
+}
+
+func TestVerifyIntegritySignature(t *testing.T) {
+	webBundleHash := sha512Helper([]byte("webbundle"))
+	integrityBlock, _ := generateSignedIntegrityBlockHelper(t, webBundleHash)
+
+	ok, err := VerifyIntegritySignature(generateEmptyIntegrityBlock(), integrityBlock.SignatureStack[0], webBundleHash)
+	if err != nil {
+		t.Fatalf("VerifyIntegritySignature. err: %v", err)
+	}
+	if !ok {
+		t.Error("Signature should be valid.")
+	}
+
+	otherWebBundleHash := sha512Helper([]byte("otherwebbundle"))
+	ok, err = VerifyIntegritySignature(generateEmptyIntegrityBlock(), integrityBlock.SignatureStack[0], otherWebBundleHash)
+	if err != nil {
+		t.Fatalf("VerifyIntegritySignature. err: %v", err)
+	}
+	if ok {
+		t.Error("Signature should not be valid for a different web bundle hash.")
+	}
+}
+
+func TestVerifyIntegritySignatureWithMalformedSignature(t *testing.T) {
+	webBundleHash := sha512Helper([]byte("webbundle"))
+	publicKey := make([]byte, ed25519.PublicKeySize)
+	signature := make([]byte, ed25519.SignatureSize)
+
+	tests := []struct {
+		name               string
+		integritySignature *IntegritySignature
+	}{
+		{
+			name:               "Missing public key",
+			integritySignature: &IntegritySignature{SignatureAttributes: SignatureAttributesMap{}, Signature: signature},
+		},
+		{
+			name:               "Too short public key",
+			integritySignature: &IntegritySignature{SignatureAttributes: SignatureAttributesMap{Ed25519publicKeyAttributeName: publicKey[1:]}, Signature: signature},
+		},
+		{
+			name:               "Too short signature",
+			integritySignature: &IntegritySignature{SignatureAttributes: SignatureAttributesMap{Ed25519publicKeyAttributeName: publicKey}, Signature: signature[1:]},
+		},
+	}
+
+	for _, test := range tests {
+		if _, err := VerifyIntegritySignature(generateEmptyIntegrityBlock(), test.integritySignature, webBundleHash); err == nil {
+			t.Errorf("%s: VerifyIntegritySignature should fail.", test.name)
+		}
+	}
+}
+
+func TestVerifyIntegrityBlock(t *testing.T) {
+	webBundleHash := sha512Helper([]byte("webbundle"))
+	integrityBlock, _ := generateSignedIntegrityBlockHelper(t, webBundleHash)
+	bogusPublicKey, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal("Failed to generate test keys")
+	}
+	integrityBlock.addNewSignatureToIntegrityBlock(GenerateSignatureAttributesWithPublicKey(bogusPublicKey), bytes.Repeat([]byte{0x01}, ed25519.SignatureSize))
+
+	result, err := VerifyIntegrityBlock(integrityBlock, webBundleHash)
+	if err != nil {
+		t.Fatalf("VerifyIntegrityBlock. err: %v", err)
+	}
+	valid, invalid := result.ValidSignatures(), result.InvalidSignatures()
+	if len(valid) != 1 || valid[0] != integrityBlock.SignatureStack[1] {
+		t.Errorf("integrityblock: got %d valid signatures, want the original signature only", len(valid))
+	}
+	if len(invalid) != 1 || invalid[0] != integrityBlock.SignatureStack[0] {
+		t.Errorf("integrityblock: got %d invalid signatures, want the bogus signature only", len(invalid))
+	}
+
+	if result.Valid {
+		t.Error("Result of a partially valid signature stack should not be valid.")
+	}
+	if len(result.Signatures) != 2 {
+		t.Fatalf("integrityblock: got %d signature results, want 2", len(result.Signatures))
+	}
+	bogusResult, originalResult := result.Signatures[0], result.Signatures[1]
+	if bogusResult.Index != 0 || bogusResult.Valid || !errors.Is(bogusResult.Err, ErrInvalidSignature) {
+		t.Errorf("integrityblock: got bogus signature result: %+v", bogusResult)
+	}
+	if !bytes.Equal(bogusResult.PublicKey, bogusPublicKey) {
+		t.Errorf("integrityblock: got public key: %x\nwant: %x", bogusResult.PublicKey, bogusPublicKey)
+	}
+	if originalResult.Index != 1 || !originalResult.Valid || originalResult.Err != nil {
+		t.Errorf("integrityblock: got original signature result: %+v", originalResult)
+	}
+}
+
+func TestVerifyIntegrityBlockResult(t *testing.T) {
+	webBundleHash := sha512Helper([]byte("webbundle"))
+	integrityBlock, pub := generateSignedIntegrityBlockHelper(t, webBundleHash)
+
+	result, err := VerifyIntegrityBlock(integrityBlock, webBundleHash)
+	if err != nil {
+		t.Fatalf("VerifyIntegrityBlock. err: %v", err)
+	}
+	if !result.Valid || len(result.Signatures) != 1 || !bytes.Equal(result.Signatures[0].PublicKey, pub) {
+		t.Errorf("integrityblock: got result: %+v", result)
+	}
+
+	// A malformed signature is reported with the reason instead of failing the whole verification.
+	integrityBlock.addNewSignatureToIntegrityBlock(SignatureAttributesMap{Ed25519publicKeyAttributeName: []byte("publickey")}, []byte("signature"))
+	result, err = VerifyIntegrityBlock(integrityBlock, webBundleHash)
+	if err != nil {
+		t.Fatalf("VerifyIntegrityBlock. err: %v", err)
+	}
+	if result.Valid || result.Signatures[0].Err == nil || errors.Is(result.Signatures[0].Err, ErrInvalidSignature) {
+		t.Errorf("integrityblock: got malformed signature result: %+v", result.Signatures[0])
+	}
+
+	// An empty signature stack must not be reported as vacuously valid.
+	if _, err := VerifyIntegrityBlock(generateEmptyIntegrityBlock(), webBundleHash); !errors.Is(err, ErrNoSignatures) {
+		t.Errorf("integrityblock: got err: %v\nwant: %v", err, ErrNoSignatures)
+	}
+	if err := VerifyAgainstAllowlist(generateEmptyIntegrityBlock(), webBundleHash, []ed25519.PublicKey{pub}); !errors.Is(err, ErrNoSignatures) {
+		t.Errorf("integrityblock: got err: %v\nwant: %v", err, ErrNoSignatures)
+	}
+}
+
+func TestVerifyIntegrityBlockWithStackedSignatures(t *testing.T) {
+	webBundleHash := sha512Helper([]byte("webbundle"))
+
+	for _, numSigners := range []int{2, 3} {
+		integrityBlock := generateEmptyIntegrityBlock()
+		var publicKeys []ed25519.PublicKey
+
+		for i := 0; i < numSigners; i++ {
+			pub, priv, err := ed25519.GenerateKey(rand.Reader)
+			if err != nil {
+				t.Fatal("Failed to generate test keys")
+			}
+			integritySignature, err := SignIntegrityBlock(integrityBlock, NewParsedEd25519KeySigningStrategy(priv), webBundleHash)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := AppendSignature(integrityBlock, integritySignature); err != nil {
+				t.Fatal(err)
+			}
+			publicKeys = append(publicKeys, pub)
+		}
+
+		// The newest signature must be the first one on the stack.
+		for i, integritySignature := range integrityBlock.SignatureStack {
+			want := publicKeys[numSigners-1-i]
+			if !bytes.Equal(integritySignature.SignatureAttributes[Ed25519publicKeyAttributeName], want) {
+				t.Errorf("%d signers: signatureStack[%d] is not signed by the expected signer.", numSigners, i)
+			}
+		}
+
+		result, err := VerifyIntegrityBlock(integrityBlock, webBundleHash)
+		if err != nil {
+			t.Fatalf("VerifyIntegrityBlock. err: %v", err)
+		}
+		valid, invalid := result.ValidSignatures(), result.InvalidSignatures()
+		if len(valid) != numSigners || len(invalid) != 0 {
+			t.Errorf("%d signers: got %d valid and %d invalid signatures", numSigners, len(valid), len(invalid))
+		}
+
+		// Swapping the order of the signatures breaks them, as each covers the ones added before it.
+		integrityBlock.SignatureStack[0], integrityBlock.SignatureStack[1] = integrityBlock.SignatureStack[1], integrityBlock.SignatureStack[0]
+		if result, _ := VerifyIntegrityBlock(integrityBlock, webBundleHash); len(result.InvalidSignatures()) == 0 {
+			t.Errorf("%d signers: reordered signatures should not be valid.", numSigners)
+		}
+	}
+}
+
+func TestAppendNilSignature(t *testing.T) {
+	if err := AppendSignature(generateEmptyIntegrityBlock(), nil); err == nil {
+		t.Error("AppendSignature should fail with a nil signature.")
+	}
+}
+
+func TestSignerPublicKeys(t *testing.T) {
+	webBundleHash := sha512Helper([]byte("webbundle"))
+	integrityBlock, pub := generateSignedIntegrityBlockHelper(t, webBundleHash)
+
+	publicKeys, err := SignerPublicKeys(integrityBlock)
+	if err != nil {
+		t.Fatalf("SignerPublicKeys. err: %v", err)
+	}
+	if len(publicKeys) != 1 || !publicKeys[0].Equal(pub) {
+		t.Errorf("integrityblock: got: %v\nwant: %v", publicKeys, pub)
+	}
+
+	integrityBlock.addNewSignatureToIntegrityBlock(SignatureAttributesMap{EcdsaP256SHA256PublicKeyAttributeName: []byte("publickey")}, []byte("signature"))
+	if _, err := SignerPublicKeys(integrityBlock); err == nil {
+		t.Error("SignerPublicKeys should fail with a signature without an Ed25519 public key.")
+	}
+
+	publicKeys = SignerPublicKeysSkipUnknown(integrityBlock)
+	if len(publicKeys) != 1 || !publicKeys[0].Equal(pub) {
+		t.Errorf("integrityblock: got: %v\nwant: %v", publicKeys, pub)
+	}
+
+	integrityBlock.SignatureStack = append(integrityBlock.SignatureStack, nil)
+	if _, err := SignerPublicKeys(integrityBlock); err == nil {
+		t.Error("SignerPublicKeys should fail with a nil signature.")
+	}
+	publicKeys = SignerPublicKeysSkipUnknown(integrityBlock)
+	if len(publicKeys) != 1 || !publicKeys[0].Equal(pub) {
+		t.Errorf("integrityblock: got: %v\nwant: %v", publicKeys, pub)
+	}
+}
+
+func TestVerifyAgainstAllowlist(t *testing.T) {
+	webBundleHash := sha512Helper([]byte("webbundle"))
+	integrityBlock, pub := generateSignedIntegrityBlockHelper(t, webBundleHash)
+	otherPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal("Failed to generate test keys")
+	}
+
+	if err := VerifyAgainstAllowlist(integrityBlock, webBundleHash, []ed25519.PublicKey{otherPub, pub}); err != nil {
+		t.Errorf("VerifyAgainstAllowlist. err: %v", err)
+	}
+
+	if err := VerifyAgainstAllowlist(integrityBlock, webBundleHash, []ed25519.PublicKey{otherPub}); !errors.Is(err, ErrSignerNotAllowed) {
+		t.Errorf("integrityblock: got err: %v\nwant: %v", err, ErrSignerNotAllowed)
+	}
+
+	otherWebBundleHash := sha512Helper([]byte("otherwebbundle"))
+	if err := VerifyAgainstAllowlist(integrityBlock, otherWebBundleHash, []ed25519.PublicKey{pub}); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("integrityblock: got err: %v\nwant: %v", err, ErrInvalidSignature)
+	}
+}
+
+func TestVerifyIntegrityBlockWithBase64Key(t *testing.T) {
+	webBundleHash := sha512Helper([]byte("webbundle"))
+	integrityBlock, pub := generateSignedIntegrityBlockHelper(t, webBundleHash)
+
+	for _, encoding := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if err := VerifyIntegrityBlockWithBase64Key(integrityBlock, webBundleHash, encoding.EncodeToString(pub)); err != nil {
+			t.Errorf("VerifyIntegrityBlockWithBase64Key with %q. err: %v", encoding.EncodeToString(pub), err)
+		}
+	}
+
+	otherPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal("Failed to generate test keys")
+	}
+	if err := VerifyIntegrityBlockWithBase64Key(integrityBlock, webBundleHash, base64.StdEncoding.EncodeToString(otherPub)); !errors.Is(err, ErrSignerNotAllowed) {
+		t.Errorf("integrityblock: got err: %v\nwant: %v", err, ErrSignerNotAllowed)
+	}
+
+	for _, invalidKey := range []string{"not base64!", base64.StdEncoding.EncodeToString(pub[:16]), ""} {
+		if err := VerifyIntegrityBlockWithBase64Key(integrityBlock, webBundleHash, invalidKey); err == nil || errors.Is(err, ErrSignerNotAllowed) {
+			t.Errorf("integrityblock: got err: %v, want a key decoding error for %q", err, invalidKey)
+		}
+	}
+}
+
+func TestSignedBytesForIndex(t *testing.T) {
+	webBundleHash := sha512Helper([]byte("webbundle"))
+	integrityBlock := generateEmptyIntegrityBlock()
+	var payloads [][]byte
+	for i := 0; i < 3; i++ {
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal("Failed to generate test keys")
+		}
+		signer := NewParsedEd25519KeySigningStrategy(priv)
+		payload, err := GenerateSignedPayload(integrityBlock, GenerateSignatureAttributesWithPublicKey(signer.PublicKey()), webBundleHash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		integritySignature, err := SignIntegrityBlock(integrityBlock, signer, webBundleHash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := AppendSignature(integrityBlock, integritySignature); err != nil {
+			t.Fatal(err)
+		}
+		// The newest signature is prepended, so the first payload belongs to the last signature on the stack.
+		payloads = append([][]byte{payload}, payloads...)
+	}
+
+	for i, want := range payloads {
+		got, err := SignedBytesForIndex(integrityBlock, i, webBundleHash)
+		if err != nil {
+			t.Fatalf("SignedBytesForIndex(%d). err: %v", i, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("integrityblock: signatureStack[%d] got: %x\nwant: %x", i, got, want)
+		}
+	}
+
+	for _, index := range []int{-1, len(payloads)} {
+		if _, err := SignedBytesForIndex(integrityBlock, index, webBundleHash); err == nil {
+			t.Errorf("SignedBytesForIndex should fail with index %d.", index)
+		}
+	}
+}
+
+func TestCheckUniqueSigners(t *testing.T) {
+	integrityBlock := generateEmptyIntegrityBlock()
+	integrityBlock.addNewSignatureToIntegrityBlock(SignatureAttributesMap{Ed25519publicKeyAttributeName: []byte("publickey1")}, []byte("signature1"))
+	integrityBlock.addNewSignatureToIntegrityBlock(SignatureAttributesMap{Ed25519publicKeyAttributeName: []byte("publickey2")}, []byte("signature2"))
+	// The same bytes as a key of another algorithm are a different signer.
+	integrityBlock.addNewSignatureToIntegrityBlock(SignatureAttributesMap{EcdsaP256SHA256PublicKeyAttributeName: []byte("publickey1")}, []byte("signature3"))
+
+	if err := CheckUniqueSigners(integrityBlock); err != nil {
+		t.Errorf("CheckUniqueSigners. err: %v", err)
+	}
+
+	integrityBlock.addNewSignatureToIntegrityBlock(SignatureAttributesMap{Ed25519publicKeyAttributeName: []byte("publickey1")}, []byte("signature4"))
+	err := CheckUniqueSigners(integrityBlock)
+	if !errors.Is(err, ErrDuplicateSigner) {
+		t.Fatalf("integrityblock: got err: %v\nwant: %v", err, ErrDuplicateSigner)
+	}
+	if !strings.Contains(err.Error(), "signatureStack[0] and signatureStack[3]") {
+		t.Errorf("integrityblock: error should identify the duplicate signatures, got: %v", err)
+	}
+}
+
+func TestPublicKeysEqual(t *testing.T) {
+	publicKey := bytes.Repeat([]byte{0x01}, ed25519.PublicKeySize)
+	differentPublicKey := append(bytes.Repeat([]byte{0x01}, ed25519.PublicKeySize-1), 0x02)
+
+	testCases := []struct {
+		name string
+		a, b []byte
+		want bool
+	}{
+		{name: "Equal", a: publicKey, b: bytes.Clone(publicKey), want: true},
+		{name: "Same length, last byte differs", a: publicKey, b: differentPublicKey, want: false},
+		{name: "Prefix", a: publicKey, b: publicKey[:ed25519.PublicKeySize-1], want: false},
+		{name: "Empty", a: publicKey, b: nil, want: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := publicKeysEqual(tc.a, tc.b); got != tc.want {
+				t.Errorf("integrityblock: got: %v\nwant: %v", got, tc.want)
+			}
+			if got := publicKeysEqual(tc.b, tc.a); got != tc.want {
+				t.Errorf("integrityblock: comparison should be symmetric, got: %v\nwant: %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestVerifyWebBundleHash(t *testing.T) {
+	webBundleBytes := []byte("webbundle")
+	integrityBlockBytes, err := generateEmptyIntegrityBlock().CborBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := bytes.NewReader(append(integrityBlockBytes, webBundleBytes...))
+
+	ok, err := VerifyWebBundleHash(r, int64(len(integrityBlockBytes)), sha512Helper(webBundleBytes))
+	if err != nil {
+		t.Fatalf("VerifyWebBundleHash. err: %v", err)
+	}
+	if !ok {
+		t.Error("Web bundle hash should match.")
+	}
+
+	ok, err = VerifyWebBundleHash(r, 0, sha512Helper(webBundleBytes))
+	if err != nil {
+		t.Fatalf("VerifyWebBundleHash. err: %v", err)
+	}
+	if ok {
+		t.Error("Web bundle hash should not match when the integrity block is hashed as well.")
+	}
+}
+
+func TestVerifyIntegrityBlockWithSha256WebBundleHash(t *testing.T) {
+	webBundleBytes := []byte("webbundle")
+	r := bytes.NewReader(webBundleBytes)
+	webBundleHash, err := ComputeWebBundleHash(r, 0, crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	integrityBlock, _ := generateSignedIntegrityBlockHelper(t, webBundleHash)
+
+	result, err := VerifyIntegrityBlock(integrityBlock, webBundleHash)
+	if err != nil {
+		t.Fatalf("VerifyIntegrityBlock. err: %v", err)
+	}
+	if !result.Valid {
+		t.Errorf("integrityblock: got result: %+v", result)
+	}
+
+	ok, err := VerifyWebBundleHashWithAlgorithm(r, 0, webBundleHash, crypto.SHA256)
+	if err != nil {
+		t.Fatalf("VerifyWebBundleHashWithAlgorithm. err: %v", err)
+	}
+	if !ok {
+		t.Error("Web bundle hash should match with the same hash algorithm.")
+	}
+	if ok, _ := VerifyWebBundleHash(r, 0, webBundleHash); ok {
+		t.Error("SHA-256 web bundle hash should not match the SHA-512 hash.")
+	}
+}
+
+func TestVerifyParsedSignatureWithUnknownAttribute(t *testing.T) {
+	webBundleHash := sha512Helper([]byte("webbundle"))
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal("Failed to generate test keys")
+	}
+
+	integrityBlock := generateEmptyIntegrityBlock()
+	signatureAttributes := GenerateSignatureAttributesWithPublicKey(pub)
+	signatureAttributes["futureAttribute"] = []byte("value")
+	payload, err := GenerateSignedPayload(integrityBlock, signatureAttributes, webBundleHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	AppendSignature(integrityBlock, &IntegritySignature{SignatureAttributes: signatureAttributes, Signature: ed25519.Sign(priv, payload)})
+
+	integrityBlockBytes, err := integrityBlock.CborBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseIntegrityBlock(bytes.NewReader(integrityBlockBytes))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The unknown attribute is covered by the signature, so dropping it would break the verification.
+	result, err := VerifyIntegrityBlock(parsed, webBundleHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Valid {
+		t.Errorf("integrityblock: got result: %+v, want valid", result.Signatures)
+	}
+}
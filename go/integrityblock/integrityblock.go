@@ -2,15 +2,20 @@ package integrityblock
 
 import (
 	"bytes"
+	"context"
+	"crypto"
 	"crypto/ed25519"
+	_ "crypto/sha256"
 	"crypto/sha512"
 	"encoding/binary"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"os"
+	"sort"
 
+	"github.com/WICG/webpackage/go/bundle/version"
 	"github.com/WICG/webpackage/go/internal/cbor"
 )
 
@@ -25,10 +30,42 @@ type IntegrityBlock struct {
 	Magic          []byte
 	Version        []byte
 	SignatureStack []*IntegritySignature
+
+	// UnknownFields contains the raw CBOR of the elements following the signature stack in the integrity block
+	// array, which only integrity blocks of newer versions have. They are kept when parsing with
+	// `ParseOptions.PreserveUnknownFields` and re-emitted only by `CborBytesPreservingUnknownFields`.
+	UnknownFields [][]byte
 }
 
+// Signature attribute names of the public keys. The attribute name of the public key also
+// identifies the algorithm with which the integrity signature was created.
+const (
+	Ed25519publicKeyAttributeName         = "ed25519PublicKey"
+	EcdsaP256SHA256PublicKeyAttributeName = "ecdsaP256SHA256PublicKey"
+)
+
+// Sizes of the public keys and signatures of the supported signing algorithms in bytes. A new signing
+// algorithm adds its own sizes here.
+const (
+	Ed25519PublicKeySize = ed25519.PublicKeySize
+	Ed25519SignatureSize = ed25519.SignatureSize
+)
+
+var (
+	ErrBundleAlreadySigned          = errors.New("integrityblock: Web bundle already contains an integrity block.")
+	ErrNegativeIntegrityBlockLength = errors.New("integrityblock: Integrity block length should never be negative.")
+	ErrBundleNotSigned              = errors.New("integrityblock: Web bundle doesn't contain an integrity block.")
+	ErrCborEncode                   = errors.New("integrityblock: CBOR encoding failed.")
+	ErrTruncatedBundle              = errors.New("integrityblock: Web bundle is truncated or not a web bundle.")
+	ErrInvalidWebBundleMagic        = errors.New("integrityblock: Web bundle doesn't start with a valid web bundle magic.")
+	ErrUnexpectedIntegrityBlockSize = errors.New("integrityblock: Integrity block array should have 3 elements.")
+	ErrNonCanonicalEncoding         = errors.New("integrityblock: Integrity block is not canonically encoded.")
+)
+
+// Sizes of the magic and the version of the integrity block in bytes, which decoders expect exactly.
 const (
-	Ed25519publicKeyAttributeName = "ed25519PublicKey"
+	integrityBlockMagicSize   = 8
+	integrityBlockVersionSize = 4
 )
 
 var IntegrityBlockMagic = []byte{0xf0, 0x9f, 0x96, 0x8b, 0xf0, 0x9f, 0x93, 0xa6}
@@ -36,10 +73,177 @@ var IntegrityBlockMagic = []byte{0xf0, 0x9f, 0x96, 0x8b, 0xf0, 0x9f, 0x93, 0xa6}
 // "b1" as bytes and 2 empty bytes
 var VersionB1 = []byte{0x31, 0x62, 0x00, 0x00}
 
+// "b2" as bytes and 2 empty bytes
+var VersionB2 = []byte{0x32, 0x62, 0x00, 0x00}
+
+// IsSupportedVersion returns true if the given version bytes match one of the integrity block versions known by this package.
+func IsSupportedVersion(version []byte) bool {
+	return bytes.Equal(version, VersionB1) || bytes.Equal(version, VersionB2)
+}
+
+// VersionString returns a human readable name of the integrity block's version, e.g. "b1", for logging purposes.
+func (ib *IntegrityBlock) VersionString() string {
+	switch {
+	case bytes.Equal(ib.Version, VersionB1):
+		return "b1"
+	case bytes.Equal(ib.Version, VersionB2):
+		return "b2"
+	default:
+		return fmt.Sprintf("unknown (%x)", ib.Version)
+	}
+}
+
+// IsEmpty returns true if the signature stack of the integrity block is empty, meaning that the web bundle is
+// not signed yet, e.g. for the integrity block returned by `ObtainIntegrityBlock`.
+func (ib *IntegrityBlock) IsEmpty() bool {
+	return ib.SignatureCount() == 0
+}
+
+// SignatureCount returns the number of integrity signatures on the signature stack.
+func (ib *IntegrityBlock) SignatureCount() int {
+	return len(ib.SignatureStack)
+}
+
+// Attribute returns the value of the signature attribute with the given name and whether it was present.
+// Attributes unknown to this package are accessible as well.
+func (is *IntegritySignature) Attribute(name string) ([]byte, bool) {
+	value, ok := is.SignatureAttributes[name]
+	return value, ok
+}
+
+// Ed25519PublicKey returns the value of the Ed25519 public key attribute and whether it was present.
+func (is *IntegritySignature) Ed25519PublicKey() ([]byte, bool) {
+	return is.Attribute(Ed25519publicKeyAttributeName)
+}
+
+// EcdsaP256SHA256PublicKey returns the value of the ECDSA P-256 public key attribute and whether it was present.
+func (is *IntegritySignature) EcdsaP256SHA256PublicKey() ([]byte, bool) {
+	return is.Attribute(EcdsaP256SHA256PublicKeyAttributeName)
+}
+
+// SignatureAttribute is a single signature attribute as returned by `SortedAttributes`.
+type SignatureAttribute struct {
+	Key   string
+	Value []byte
+}
+
+// SortedAttributes returns the signature attributes of the integrity signature in the canonical CBOR key order
+// in which `CborBytes` encodes them, giving a stable iteration order e.g. for logging and comparisons.
+func SortedAttributes(is *IntegritySignature) []SignatureAttribute {
+	if is == nil {
+		return nil
+	}
+	names := canonicalAttributeNames(is.SignatureAttributes)
+	attributes := make([]SignatureAttribute, 0, len(names))
+	for _, name := range names {
+		attributes = append(attributes, SignatureAttribute{Key: name, Value: is.SignatureAttributes[name]})
+	}
+	return attributes
+}
+
+// Equal tells whether the integrity signatures have the same signature attributes and the same signature.
+func (is *IntegritySignature) Equal(other *IntegritySignature) bool {
+	if is == nil || other == nil {
+		return is == other
+	}
+	if !bytes.Equal(is.Signature, other.Signature) || len(is.SignatureAttributes) != len(other.SignatureAttributes) {
+		return false
+	}
+	for name, value := range is.SignatureAttributes {
+		otherValue, ok := other.SignatureAttributes[name]
+		if !ok || !bytes.Equal(value, otherValue) {
+			return false
+		}
+	}
+	return true
+}
+
+// Key returns a string which is the same for two integrity signatures exactly when they are `Equal`, meant for
+// using integrity signatures as map keys, e.g. for deduplicating them. The key is the hex encoded sequence of the
+// attribute names and values in the canonical order followed by the signature, each prefixed with its length.
+// The key of a nil integrity signature is empty.
+func (is *IntegritySignature) Key() string {
+	if is == nil {
+		return ""
+	}
+	var buf bytes.Buffer
+	for _, attribute := range SortedAttributes(is) {
+		writeLengthPrefixed(&buf, []byte(attribute.Key))
+		writeLengthPrefixed(&buf, attribute.Value)
+	}
+	writeLengthPrefixed(&buf, is.Signature)
+	return hex.EncodeToString(buf.Bytes())
+}
+
+// validateSignatureSize checks that the size of the signature is valid for the signing algorithm identified
+// by the public key attribute.
+func (is *IntegritySignature) validateSignatureSize() error {
+	attributeName, err := is.SignatureAttributes.publicKeyAttributeName()
+	if err != nil {
+		return err
+	}
+
+	info := attributeRegistry[attributeName]
+	if len(is.Signature) < info.MinSignatureSize || len(is.Signature) > info.MaxSignatureSize {
+		if info.MinSignatureSize == info.MaxSignatureSize {
+			return fmt.Errorf("integrityblock: Signature for %q should be %d bytes, got %d bytes.", attributeName, info.MinSignatureSize, len(is.Signature))
+		}
+		return fmt.Errorf("integrityblock: Signature for %q should be %d to %d bytes, got %d bytes.", attributeName, info.MinSignatureSize, info.MaxSignatureSize, len(is.Signature))
+	}
+	return nil
+}
+
+// isPublicKeyAttributeName tells whether the attribute name is registered as a public key attribute, i.e. is one
+// of `PublicKeyAttributeNames`.
+func isPublicKeyAttributeName(name string) bool {
+	return attributeRegistry[name].Algorithm != ""
+}
+
+// publicKeyAttributeName returns the name of the public key attribute in the signature attributes, which
+// identifies the signing algorithm. Exactly one public key attribute is expected to be present.
+func (sa SignatureAttributesMap) publicKeyAttributeName() (string, error) {
+	found := ""
+	for _, name := range canonicalAttributeNames(sa) {
+		if !isPublicKeyAttributeName(name) {
+			continue
+		}
+		if found != "" {
+			return "", fmt.Errorf("integrityblock: Signature attributes contain both %q and %q public keys.", found, name)
+		}
+		found = name
+	}
+	if found == "" {
+		return "", errors.New("integrityblock: Signature attributes are missing a public key attribute.")
+	}
+	return found, nil
+}
+
+// canonicalAttributeNames returns the attribute names in the canonical CBOR order of RFC 7049 section 3.9:
+// shorter names first and names of the same length in bytewise lexicographic order, so "a" < "ab" < "bb".
+// For text string keys this is the same as the bytewise lexicographic order of their CBOR encodings, as the
+// encoded text string starts with its length.
+func canonicalAttributeNames(signatureAttributes SignatureAttributesMap) []string {
+	names := make([]string, 0, len(signatureAttributes))
+	for name := range signatureAttributes {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if len(names[i]) != len(names[j]) {
+			return len(names[i]) < len(names[j])
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
 // cborBytes writes the signature attributes map as CBOR using the given encoder so that the map's key is text string and value byte string.
+// The map entries are written in the order of `canonicalAttributeNames`, so that the signed bytes match the
+// other implementations of the integrity block. `EncodeMap` sorts the entries by their encoded keys, which
+// keeps that order.
 func (sa SignatureAttributesMap) cborBytes(enc *cbor.Encoder) error {
-	mes := []*cbor.MapEntryEncoder{}
-	for key, value := range sa {
+	mes := make([]*cbor.MapEntryEncoder, 0, len(sa))
+	for _, key := range canonicalAttributeNames(sa) {
+		value := sa[key]
 		mes = append(mes,
 			cbor.GenerateMapEntry(func(keyE *cbor.Encoder, valueE *cbor.Encoder) {
 				keyE.EncodeTextString(key)
@@ -47,53 +251,279 @@ func (sa SignatureAttributesMap) cborBytes(enc *cbor.Encoder) error {
 			}))
 	}
 	if err := enc.EncodeMap(mes); err != nil {
-		return fmt.Errorf("integrityblock: Failed to encode signature attributes: %v", err)
+		return cborEncodeError("signature attributes", err)
 	}
 	return nil
 }
 
 // cborBytes writes the integrity signature as CBOR using the given encoder containing the signature attributes and the signature.
 func (is *IntegritySignature) cborBytes(enc *cbor.Encoder) error {
-	enc.EncodeArrayHeader(2)
+	if err := enc.EncodeArrayHeader(2); err != nil {
+		return cborEncodeError("integrity signature array header", err)
+	}
 
 	if err := is.SignatureAttributes.cborBytes(enc); err != nil {
-		return fmt.Errorf("integrityblock: Failed to encode signature attributes: %v", err)
+		return err
 	}
 
 	if err := enc.EncodeByteString(is.Signature); err != nil {
-		return fmt.Errorf("integrityblock: Failed to encode signature: %v", err)
+		return cborEncodeError("signature", err)
 	}
 	return nil
 }
 
+// cborEncodeError wraps the error of encoding the given field with `ErrCborEncode`.
+func cborEncodeError(field string, err error) error {
+	return fmt.Errorf("%w Failed to encode %s: %v", ErrCborEncode, field, err)
+}
+
 // CborBytes returns the CBOR encoded bytes of the integrity block.
 func (ib *IntegrityBlock) CborBytes() ([]byte, error) {
-	var buf bytes.Buffer
-	enc := cbor.NewEncoder(&buf)
+	return ib.cborBytes(false)
+}
 
-	err := enc.EncodeArrayHeader(3)
+// CborBytesPreservingUnknownFields is like `CborBytes`, but appends the `UnknownFields` to the integrity block
+// array, so that an integrity block of a newer version survives parsing and re-encoding byte for byte. The
+// signed payload is always built from `CborBytes`.
+func (ib *IntegrityBlock) CborBytesPreservingUnknownFields() ([]byte, error) {
+	return ib.cborBytes(true)
+}
+
+// CborBytesWithLengthPrefix returns the CBOR encoded bytes of the integrity block like `CborBytes`, preceded by
+// their length as an 8-byte big-endian integer, mirroring the web bundle's trailing length at the front, e.g.
+// for embedding the integrity block in framed protocols.
+func (ib *IntegrityBlock) CborBytesWithLengthPrefix() ([]byte, error) {
+	integrityBlockBytes, err := ib.CborBytes()
 	if err != nil {
 		return nil, err
 	}
+	var buf bytes.Buffer
+	writeLengthPrefixed(&buf, integrityBlockBytes)
+	return buf.Bytes(), nil
+}
 
-	err = enc.EncodeByteString(ib.Magic)
-	if err != nil {
+func (ib *IntegrityBlock) cborBytes(preserveUnknownFields bool) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := ib.encodeTo(&buf, preserveUnknownFields); err != nil {
 		return nil, err
 	}
+	return buf.Bytes(), nil
+}
+
+// SerializedSize returns the number of bytes `CborBytes` would return, i.e. the size the integrity block
+// occupies at the start of a signed web bundle and thus the payload offset. The encoding is only counted, so
+// the full output is never allocated.
+func (ib *IntegrityBlock) SerializedSize() (int, error) {
+	var sc sizeCounter
+	if err := ib.encodeTo(&sc, false); err != nil {
+		return 0, err
+	}
+	return int(sc), nil
+}
+
+// sizeCounter is an `io.Writer` which discards everything written to it, counting only the number of bytes.
+type sizeCounter int
+
+func (sc *sizeCounter) Write(p []byte) (int, error) {
+	*sc += sizeCounter(len(p))
+	return len(p), nil
+}
+
+// encodeTo writes the CBOR encoded integrity block to w.
+func (ib *IntegrityBlock) encodeTo(w io.Writer, preserveUnknownFields bool) error {
+	// Sizes are checked even without `Validate`, as no decoder would accept the encoded integrity block otherwise.
+	if err := ib.validateHeaderSizes(); err != nil {
+		return err
+	}
+
+	enc := cbor.NewEncoder(w)
+
+	numFields := 3
+	if preserveUnknownFields {
+		// The unknown fields are counted in the array header, so each of them must be exactly one CBOR item.
+		for i, unknownField := range ib.UnknownFields {
+			if err := checkSingleCborItem(unknownField); err != nil {
+				return cborEncodeError(fmt.Sprintf("unknownFields[%d]", i), err)
+			}
+		}
+		numFields += len(ib.UnknownFields)
+	}
+	if err := enc.EncodeArrayHeader(numFields); err != nil {
+		return cborEncodeError("integrity block array header", err)
+	}
 
-	err = enc.EncodeByteString(ib.Version)
+	if err := enc.EncodeByteString(ib.Magic); err != nil {
+		return cborEncodeError("magic", err)
+	}
+
+	if err := enc.EncodeByteString(ib.Version); err != nil {
+		return cborEncodeError("version", err)
+	}
+
+	if err := ib.encodeSignatureStack(enc); err != nil {
+		return err
+	}
+
+	if preserveUnknownFields {
+		// The unknown fields are already CBOR encoded, so they are copied as they are.
+		for _, unknownField := range ib.UnknownFields {
+			if _, err := w.Write(unknownField); err != nil {
+				return cborEncodeError("unknown field", err)
+			}
+		}
+	}
+	return nil
+}
+
+// checkSingleCborItem checks that `item` is exactly one well-formed CBOR item.
+func checkSingleCborItem(item []byte) error {
+	rawItem, err := cbor.NewDecoder(bytes.NewReader(item)).DecodeRawItem()
 	if err != nil {
+		return err
+	}
+	if len(rawItem) != len(item) {
+		return fmt.Errorf("%d trailing bytes after the CBOR item", len(item)-len(rawItem))
+	}
+	return nil
+}
+
+// signatureStackCborBytes returns the CBOR encoded signature stack array of the integrity block.
+func (ib *IntegrityBlock) signatureStackCborBytes() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := ib.encodeSignatureStack(cbor.NewEncoder(&buf)); err != nil {
 		return nil, err
 	}
+	return buf.Bytes(), nil
+}
 
-	err = enc.EncodeArrayHeader(len(ib.SignatureStack))
-	for _, integritySignature := range ib.SignatureStack {
+// encodeSignatureStack writes the signature stack array of the integrity block using the given encoder.
+func (ib *IntegrityBlock) encodeSignatureStack(enc *cbor.Encoder) error {
+	if err := enc.EncodeArrayHeader(len(ib.SignatureStack)); err != nil {
+		return cborEncodeError("signature stack array header", err)
+	}
+
+	// The array header declares the number of signatures, so a nil signature cannot be skipped.
+	for i, integritySignature := range ib.SignatureStack {
+		if integritySignature == nil {
+			return fmt.Errorf("integrityblock: signatureStack[%d] is nil.", i)
+		}
 		if err := integritySignature.cborBytes(enc); err != nil {
-			return nil, err
+			return err
 		}
 	}
+	return nil
+}
 
-	return buf.Bytes(), nil
+// validateHeaderSizes checks that the magic and the version have the sizes decoders expect.
+func (ib *IntegrityBlock) validateHeaderSizes() error {
+	if len(ib.Magic) != integrityBlockMagicSize {
+		return fmt.Errorf("integrityblock: Magic should be %d bytes, got %d bytes.", integrityBlockMagicSize, len(ib.Magic))
+	}
+	if len(ib.Version) != integrityBlockVersionSize {
+		return fmt.Errorf("integrityblock: Version should be %d bytes, got %d bytes.", integrityBlockVersionSize, len(ib.Version))
+	}
+	return nil
+}
+
+// Validate checks that the integrity block has the expected magic, a supported version and that every
+// integrity signature on the signature stack has exactly one public key attribute and a signature whose
+// size matches the signing algorithm. It is meant to catch programming errors before a malformed integrity
+// block gets serialized.
+func (ib *IntegrityBlock) Validate() error {
+	if err := ib.validateHeaderSizes(); err != nil {
+		return err
+	}
+	if !bytes.Equal(ib.Magic, IntegrityBlockMagic) {
+		return fmt.Errorf("integrityblock: Unexpected magic %x.", ib.Magic)
+	}
+	if !IsSupportedVersion(ib.Version) {
+		return fmt.Errorf("integrityblock: Unsupported version %x.", ib.Version)
+	}
+	for i, integritySignature := range ib.SignatureStack {
+		if integritySignature == nil {
+			return fmt.Errorf("integrityblock: signatureStack[%d] is nil.", i)
+		}
+		if len(integritySignature.Signature) == 0 {
+			return fmt.Errorf("integrityblock: signatureStack[%d] has an empty signature.", i)
+		}
+		if err := integritySignature.validateSignatureSize(); err != nil {
+			return fmt.Errorf("integrityblock: signatureStack[%d]: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// ValidatedCborBytes returns the CBOR encoded bytes of the integrity block like `CborBytes`, but calls
+// `Validate` first and fails instead of encoding a malformed integrity block.
+func (ib *IntegrityBlock) ValidatedCborBytes() ([]byte, error) {
+	if err := ib.Validate(); err != nil {
+		return nil, err
+	}
+	return ib.CborBytes()
+}
+
+// Clone returns a deep copy of the integrity block, so that the magic, the version, the signature stack,
+// including the signature attributes maps, and the unknown fields of the copy can be modified without
+// affecting the original.
+func (ib *IntegrityBlock) Clone() *IntegrityBlock {
+	var signatureStack []*IntegritySignature
+	if ib.SignatureStack != nil {
+		signatureStack = make([]*IntegritySignature, len(ib.SignatureStack))
+	}
+	for i, integritySignature := range ib.SignatureStack {
+		if integritySignature == nil {
+			continue
+		}
+		var signatureAttributes SignatureAttributesMap
+		if integritySignature.SignatureAttributes != nil {
+			signatureAttributes = make(SignatureAttributesMap, len(integritySignature.SignatureAttributes))
+		}
+		for key, value := range integritySignature.SignatureAttributes {
+			signatureAttributes[key] = bytes.Clone(value)
+		}
+		signatureStack[i] = &IntegritySignature{
+			SignatureAttributes: signatureAttributes,
+			Signature:           bytes.Clone(integritySignature.Signature),
+		}
+	}
+
+	var unknownFields [][]byte
+	if ib.UnknownFields != nil {
+		unknownFields = make([][]byte, len(ib.UnknownFields))
+	}
+	for i, unknownField := range ib.UnknownFields {
+		unknownFields[i] = bytes.Clone(unknownField)
+	}
+
+	return &IntegrityBlock{
+		Magic:          bytes.Clone(ib.Magic),
+		Version:        bytes.Clone(ib.Version),
+		SignatureStack: signatureStack,
+		UnknownFields:  unknownFields,
+	}
+}
+
+// Sha512 returns the SHA-512 digest of the CBOR encoded integrity block. As the encoding is deterministic, the
+// digest changes exactly when the magic, the version or any of the integrity signatures change, which makes it
+// suitable for change detection and caching without hashing the whole signed web bundle.
+func (ib *IntegrityBlock) Sha512() ([]byte, error) {
+	integrityBlockBytes, err := ib.CborBytes()
+	if err != nil {
+		return nil, err
+	}
+	digest := sha512.Sum512(integrityBlockBytes)
+	return digest[:], nil
+}
+
+// NewEmptyIntegrityBlock creates an empty integrity block of the version b1 without any integrity signatures,
+// e.g. for producers which prepend the integrity block to the web bundle and sign it in a later stage. Its
+// `CborBytes` is the canonical minimal encoding with an empty signature stack array. The magic and the version
+// are copies, so modifying them doesn't affect `IntegrityBlockMagic` or `VersionB1`.
+func NewEmptyIntegrityBlock() *IntegrityBlock {
+	integrityBlock := generateEmptyIntegrityBlock()
+	integrityBlock.Magic = bytes.Clone(integrityBlock.Magic)
+	integrityBlock.Version = bytes.Clone(integrityBlock.Version)
+	return integrityBlock
 }
 
 // generateEmptyIntegrityBlock creates an empty integrity block which does not have any integrity signatures in the signature stack yet.
@@ -108,50 +538,287 @@ func generateEmptyIntegrityBlock() *IntegrityBlock {
 	return integrityBlock
 }
 
-// readWebBundlePayloadLength returns the length of the web bundle parsed from the last 8 bytes of the web bundle file.
+// LengthReader returns the length of the web bundle read from `r`, whose total size including a possible
+// integrity block is `size`. `ReadWebBundlePayloadLengthAt` is the default implementation reading the web
+// bundle's trailing length, and others can support framings which place the length elsewhere.
+type LengthReader func(r io.ReaderAt, size int64) (int64, error)
+
+// readWebBundlePayloadLength returns the length of the web bundle of `size` bytes read from `r` with
+// `readLength`, or parsed from the last 8 bytes of the web bundle if `readLength` is nil.
 // [Web Bundle's Trailing Length]: https://wpack-wg.github.io/bundled-responses/draft-ietf-wpack-bundled-responses.html#name-trailing-length
-func readWebBundlePayloadLength(bundleFile *os.File) (int64, error) {
-	// Finds the offset, from which the 8 bytes containing the web bundle length start.
-	_, err := bundleFile.Seek(-8, io.SeekEnd)
+func readWebBundlePayloadLength(r io.ReaderAt, size int64, readLength LengthReader) (int64, error) {
+	if readLength == nil {
+		readLength = ReadWebBundlePayloadLengthAt
+	}
+	return readLength(r, size)
+}
+
+// ReadWebBundlePayloadLengthAt returns the length of the web bundle parsed from the last 8 bytes of the
+// web bundle, whose total size including a possible integrity block is `size`. Unlike reading the length
+// from an *os.File, this works for web bundles held in memory, e.g. with bytes.Reader.
+func ReadWebBundlePayloadLengthAt(r io.ReaderAt, size int64) (int64, error) {
+	if size < 8 {
+		return 0, fmt.Errorf("%w Web bundle of %d bytes is too small to contain the trailing length.", ErrTruncatedBundle, size)
+	}
+
+	webBundleLengthBytes := make([]byte, 8)
+	if _, err := r.ReadAt(webBundleLengthBytes, size-8); err != nil {
+		return 0, err
+	}
+
+	return decodeWebBundlePayloadLength(webBundleLengthBytes)
+}
+
+// minWebBundleSize is the size of the smallest possible web bundle: the header magic with the array header
+// (10 bytes), the version (5 bytes) and the trailing length as an 8-byte CBOR byte string (9 bytes).
+const minWebBundleSize = 24
+
+// decodeWebBundlePayloadLength decodes the big-endian trailing length of a web bundle and checks that it is
+// at least the size of the smallest possible web bundle.
+func decodeWebBundlePayloadLength(webBundleLengthBytes []byte) (int64, error) {
+	webBundleLen := binary.BigEndian.Uint64(webBundleLengthBytes)
+	if webBundleLen < minWebBundleSize {
+		return 0, fmt.Errorf("%w Web bundle length %d is smaller than the minimum web bundle size of %d bytes.", ErrTruncatedBundle, webBundleLen, minWebBundleSize)
+	}
+	return int64(webBundleLen), nil
+}
+
+// IntegrityBlockLength returns the length of the integrity block preceding the web bundle bytes, which is
+// also the offset where the web bundle bytes start. It is calculated from the web bundle's trailing length
+// without parsing the integrity block, so 0 means that the web bundle doesn't have an integrity block.
+func IntegrityBlockLength(bundleFile *os.File) (int64, error) {
+	fileStats, err := bundleFile.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return integrityBlockLengthAt(bundleFile, fileStats.Size(), nil)
+}
+
+// ReadIntegrityBlockBytes returns the CBOR encoded integrity block of the signed web bundle at `path`, e.g. for
+// storing it separately. The length of the integrity block is derived from the web bundle's trailing length like
+// in `IntegrityBlockLength`, and the integrity block is not parsed. It fails with `ErrBundleNotSigned` if the
+// web bundle doesn't have an integrity block.
+func ReadIntegrityBlockBytes(path string) ([]byte, error) {
+	bundleFile, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer bundleFile.Close()
+
+	integrityBlockLen, err := IntegrityBlockLength(bundleFile)
+	if err != nil {
+		return nil, err
+	}
+	if integrityBlockLen == 0 {
+		return nil, ErrBundleNotSigned
+	}
+
+	integrityBlockBytes := make([]byte, integrityBlockLen)
+	if _, err := bundleFile.ReadAt(integrityBlockBytes, 0); err != nil {
+		return nil, err
+	}
+	return integrityBlockBytes, nil
+}
+
+// PreparePayload finds the start of the web bundle bytes from the web bundle's trailing length like
+// `IntegrityBlockLength` and computes the SHA-512 hash of the web bundle bytes, returning both values needed for
+// signing. The integrity block, if any, is not parsed. The file is left positioned at its end.
+func PreparePayload(bundleFile *os.File) (hash []byte, payloadOffset int64, err error) {
+	payloadOffset, err = IntegrityBlockLength(bundleFile)
+	if err != nil {
+		return nil, 0, err
+	}
+	hash, err = ComputeWebBundleSha512(bundleFile, payloadOffset)
+	if err != nil {
+		return nil, 0, err
+	}
+	return hash, payloadOffset, nil
+}
+
+// integrityBlockLengthFromSeeker is like `IntegrityBlockLength`, but works for any io.ReadSeeker by seeking to
+// the end to find out the size of the signed web bundle.
+func integrityBlockLengthFromSeeker(rs io.ReadSeeker) (int64, error) {
+	size, err := rs.Seek(0, io.SeekEnd)
 	if err != nil {
 		return 0, err
 	}
+	return integrityBlockLengthAt(readerAt(rs), size, nil)
+}
 
-	// Reads from the offset to the end of the file (those 8 bytes).
-	webBundleLengthBytes, err := ioutil.ReadAll(bundleFile)
+// integrityBlockLengthAt is like `IntegrityBlockLength` for a web bundle of `size` bytes read from `r`, with the
+// length of the web bundle read by `readLength` (see `readWebBundlePayloadLength`). Every lookup of the
+// integrity block length goes through it.
+func integrityBlockLengthAt(r io.ReaderAt, size int64, readLength LengthReader) (int64, error) {
+	webBundleLen, err := readWebBundlePayloadLength(r, size, readLength)
 	if err != nil {
 		return 0, err
 	}
 
-	return int64(binary.BigEndian.Uint64(webBundleLengthBytes)), nil
+	integrityBlockLen := size - webBundleLen
+	if integrityBlockLen < 0 {
+		return -1, fmt.Errorf("%w Web bundle length big endian seems to be bigger than the size of the file.", ErrNegativeIntegrityBlockLength)
+	}
+	return integrityBlockLen, nil
 }
 
-// obtainIntegrityBlock returns either the existing integrity block parsed (not supported in v1) or a newly
-// created empty integrity block. Integrity block preceeds the actual web bundle bytes. The second return
-// value marks the offset from which point onwards we need to copy the web bundle bytes from. It will be
-// needed later in the signing process (TODO) because we cannot rely on the integrity block length, because
-// we don't know if the integrity block already existed or not.
-func ObtainIntegrityBlock(bundleFile *os.File) (*IntegrityBlock, int64, error) {
-	webBundleLen, err := readWebBundlePayloadLength(bundleFile)
+// readerAt returns `rs` as an io.ReaderAt, which bytes.Reader and *os.File already are, or otherwise an
+// io.ReaderAt which seeks `rs` before every read.
+func readerAt(rs io.ReadSeeker) io.ReaderAt {
+	if r, ok := rs.(io.ReaderAt); ok {
+		return r
+	}
+	return seekingReaderAt{rs}
+}
+
+type seekingReaderAt struct {
+	rs io.ReadSeeker
+}
+
+func (sra seekingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if _, err := sra.rs.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+	return io.ReadFull(sra.rs, p)
+}
+
+// StripIntegrityBlock returns a reader over just the web bundle bytes of the signed web bundle, which can
+// then e.g. be signed again. The integrity block is located using the web bundle's trailing length, and it
+// is parsed to make sure that the stripped bytes really are a valid integrity block. It fails with
+// `ErrBundleNotSigned` if the web bundle doesn't contain an integrity block.
+func StripIntegrityBlock(signedBundle io.ReadSeeker) (io.Reader, error) {
+	if _, _, err := parseExistingIntegrityBlock(signedBundle); err != nil {
+		return nil, err
+	}
+	return signedBundle, nil
+}
+
+// parseExistingIntegrityBlock parses the integrity block of the signed web bundle and checks that its length
+// matches the one implied by the web bundle's trailing length. It returns the integrity block and its length,
+// leaving the reader positioned at the start of the web bundle bytes.
+func parseExistingIntegrityBlock(signedBundle io.ReadSeeker) (*IntegrityBlock, int64, error) {
+	integrityBlockLen, err := integrityBlockLengthFromSeeker(signedBundle)
+	if err != nil {
+		return nil, 0, err
+	}
+	if integrityBlockLen == 0 {
+		return nil, 0, ErrBundleNotSigned
+	}
+
+	if _, err := signedBundle.Seek(0, io.SeekStart); err != nil {
+		return nil, 0, err
+	}
+	integrityBlock, err := ParseIntegrityBlock(signedBundle)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// The parser doesn't read ahead, so the current position is where the integrity block ended.
+	parsedLen, err := signedBundle.Seek(0, io.SeekCurrent)
 	if err != nil {
 		return nil, 0, err
 	}
+	if parsedLen != integrityBlockLen {
+		return nil, 0, fmt.Errorf("integrityblock: Parsed integrity block is %d bytes, but the trailing length implies %d bytes.", parsedLen, integrityBlockLen)
+	}
+
+	return integrityBlock, integrityBlockLen, nil
+}
+
+// SplitSignedBundle returns the bytes of the integrity block of the signed web bundle and a reader positioned
+// at the start of the web bundle bytes, so that they can be handled separately. The integrity block is parsed
+// and validated like in `StripIntegrityBlock` to find out its length.
+func SplitSignedBundle(signedBundle io.ReadSeeker) ([]byte, io.Reader, error) {
+	payload, err := StripIntegrityBlock(signedBundle)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	integrityBlockLen, err := signedBundle.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := signedBundle.Seek(0, io.SeekStart); err != nil {
+		return nil, nil, err
+	}
+
+	// Reading the integrity block bytes leaves the reader positioned at the start of the web bundle bytes.
+	integrityBlockBytes := make([]byte, integrityBlockLen)
+	if _, err := io.ReadFull(signedBundle, integrityBlockBytes); err != nil {
+		return nil, nil, err
+	}
+	return integrityBlockBytes, payload, nil
+}
+
+// VerifyWebBundleMagic reads the leading header and version magic bytes of a web bundle from `r` and checks
+// that they belong to a supported web bundle version. Used e.g. after `StripIntegrityBlock` to tell apart an
+// integrity block pointing to garbage from a valid unsigned web bundle. It fails with `ErrInvalidWebBundleMagic`.
+func VerifyWebBundleMagic(r io.Reader) error {
+	if _, err := version.ParseMagicBytes(r); err != nil {
+		return fmt.Errorf("%w %v", ErrInvalidWebBundleMagic, err)
+	}
+	return nil
+}
+
+// IntegrityBlockInfo describes the integrity block of a web bundle file as returned by `ObtainIntegrityBlockInfo`.
+type IntegrityBlockInfo struct {
+	// Block is the existing integrity block parsed, or a newly created empty one if the web bundle is unsigned.
+	Block *IntegrityBlock
+	// PayloadOffset is the length of the existing integrity block, meaning the offset where the web bundle
+	// bytes start. It is 0 if the web bundle is unsigned.
+	PayloadOffset int64
+	// PreExisting tells whether the web bundle already contained the integrity block.
+	PreExisting bool
+}
+
+// ObtainIntegrityBlockInfo is like `ObtainIntegrityBlock`, but instead of failing for an already signed web
+// bundle, it parses and returns the existing integrity block with `PreExisting` set.
+func ObtainIntegrityBlockInfo(bundleFile *os.File) (*IntegrityBlockInfo, error) {
+	integrityBlockLen, err := IntegrityBlockLength(bundleFile)
+	if err != nil {
+		return nil, err
+	}
+	if integrityBlockLen == 0 {
+		return &IntegrityBlockInfo{Block: generateEmptyIntegrityBlock()}, nil
+	}
+
+	integrityBlock, payloadOffset, err := parseExistingIntegrityBlock(bundleFile)
+	if err != nil {
+		return nil, err
+	}
+	return &IntegrityBlockInfo{Block: integrityBlock, PayloadOffset: payloadOffset, PreExisting: true}, nil
+}
+
+// ObtainIntegrityBlock returns a newly created empty integrity block for signing the unsigned web bundle. If the
+// web bundle already has an integrity block, which precedes the actual web bundle bytes, it fails with
+// `ErrBundleAlreadySigned` without parsing it. The second return value, `payloadOffset`, is the length of the
+// existing integrity block in either case and marks the offset from which point onwards we need to copy the web
+// bundle bytes from. Use `ObtainIntegrityBlockInfo` to get the existing integrity block parsed instead.
+func ObtainIntegrityBlock(bundleFile *os.File) (integrityBlock *IntegrityBlock, payloadOffset int64, err error) {
 	fileStats, err := bundleFile.Stat()
 	if err != nil {
 		return nil, 0, err
 	}
+	return ObtainIntegrityBlockFrom(bundleFile, fileStats.Size(), nil)
+}
 
-	integrityBlockLen := fileStats.Size() - webBundleLen
-	if integrityBlockLen < 0 {
-		return nil, -1, errors.New("Integrity block length should never be negative. Web bundle length big endian seems to be bigger than the size of the file.")
+// ObtainIntegrityBlockFrom is like `ObtainIntegrityBlock`, but works for a web bundle of `size` bytes read from
+// any seekable stream, e.g. a bytes.Reader over a web bundle held in memory, so that signing doesn't need a
+// temporary file. If `rs` is not an io.ReaderAt, the trailing length is read by seeking, so `rs` must be seeked
+// to `payloadOffset` before reading the web bundle bytes. The length of the web bundle is read with `readLength`,
+// e.g. for experimental formats placing the length differently, and a nil `readLength` reads the web bundle's
+// trailing length. The rest of the package always reads the trailing length.
+func ObtainIntegrityBlockFrom(rs io.ReadSeeker, size int64, readLength LengthReader) (integrityBlock *IntegrityBlock, payloadOffset int64, err error) {
+	integrityBlockLen, err := integrityBlockLengthAt(readerAt(rs), size, readLength)
+	if err != nil {
+		return nil, integrityBlockLen, err
 	}
 
 	if integrityBlockLen != 0 {
-		// Read existing integrity block. Not supported in v1.
-		return nil, integrityBlockLen, errors.New("Web bundle already contains an integrity block. Please provide an unsigned web bundle.")
+		// The existing integrity block is not parsed here, see `ObtainIntegrityBlockInfo`.
+		return nil, integrityBlockLen, fmt.Errorf("%w Please provide an unsigned web bundle.", ErrBundleAlreadySigned)
 	}
 
-	integrityBlock := generateEmptyIntegrityBlock()
+	integrityBlock = generateEmptyIntegrityBlock()
 	return integrityBlock, integrityBlockLen, nil
 }
 
@@ -164,21 +831,240 @@ func (integrityBlock *IntegrityBlock) addNewSignatureToIntegrityBlock(signatureA
 	integrityBlock.SignatureStack = append(is, integrityBlock.SignatureStack...)
 }
 
+// AppendSignature pushes the given integrity signature on top of the signature stack, making it the
+// first element. The signature is expected to have been created over the integrity block in its state
+// before this call, e.g. with `SignIntegrityBlock`, so that every signature on the stack covers the
+// signatures added before it.
+func AppendSignature(ib *IntegrityBlock, is *IntegritySignature) error {
+	if is == nil {
+		return errors.New("integrityblock: Cannot append a nil integrity signature.")
+	}
+	ib.addNewSignatureToIntegrityBlock(is.SignatureAttributes, is.Signature)
+	return nil
+}
+
+// SignaturesByAlgorithm returns the integrity signatures on the signature stack whose single public key
+// attribute is `attrName`, e.g. `Ed25519publicKeyAttributeName`, in the stack's order, so that policies can
+// verify or trust only the signatures of certain signing algorithms. Signatures with no or several public key
+// attributes are skipped. It returns nil if no signature matches.
+func SignaturesByAlgorithm(ib *IntegrityBlock, attrName string) []*IntegritySignature {
+	var signatures []*IntegritySignature
+	for _, integritySignature := range ib.SignatureStack {
+		if integritySignature == nil {
+			continue
+		}
+		if name, err := integritySignature.SignatureAttributes.publicKeyAttributeName(); err == nil && name == attrName {
+			signatures = append(signatures, integritySignature)
+		}
+	}
+	return signatures
+}
+
+// EachSignature calls `fn` for every integrity signature on the signature stack in the stack's order, meaning
+// the newest signature first. The walk stops when `fn` returns true or an error, and the error is returned as is.
+func EachSignature(ib *IntegrityBlock, fn func(index int, is *IntegritySignature) (stop bool, err error)) error {
+	if ib == nil {
+		return errors.New("integrityblock: Cannot iterate over a nil integrity block.")
+	}
+	for i, integritySignature := range ib.SignatureStack {
+		stop, err := fn(i, integritySignature)
+		if err != nil {
+			return err
+		}
+		if stop {
+			return nil
+		}
+	}
+	return nil
+}
+
+// WriteSignedBundle writes the validated CBOR encoded integrity block followed by the web bundle bytes read from
+// `bundle` into `w`. The web bundle bytes are copied in chunks, so the web bundle is never held in memory
+// as a whole. It returns the total number of bytes written.
+func WriteSignedBundle(w io.Writer, ib *IntegrityBlock, bundle io.Reader) (int64, error) {
+	integrityBlockBytes, err := ib.ValidatedCborBytes()
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := w.Write(integrityBlockBytes)
+	written := int64(n)
+	if err != nil {
+		return written, err
+	}
+
+	// io.Copy() will do chunked read/write under the hood
+	m, err := io.Copy(w, bundle)
+	written += m
+	return written, err
+}
+
 // ComputeWebBundleSha512 computes the SHA-512 hash over the given web bundle file.
 func ComputeWebBundleSha512(bundleFile io.ReadSeeker, offset int64) ([]byte, error) {
-	h := sha512.New()
+	return ComputeWebBundleHash(bundleFile, offset, crypto.SHA512)
+}
 
+// CompareWebBundlePayloads tells whether the two web bundle files contain the same web bundle bytes, ignoring
+// their integrity blocks, e.g. to check that re-signing a web bundle left its payload unchanged. The start of the
+// web bundle bytes in each file is found with `IntegrityBlockLength`, so either file may be signed or unsigned.
+func CompareWebBundlePayloads(a, b *os.File) (bool, error) {
+	hashA, err := computeWebBundlePayloadSha512(a)
+	if err != nil {
+		return false, fmt.Errorf("integrityblock: Failed to hash the first web bundle: %w", err)
+	}
+	hashB, err := computeWebBundlePayloadSha512(b)
+	if err != nil {
+		return false, fmt.Errorf("integrityblock: Failed to hash the second web bundle: %w", err)
+	}
+	return bytes.Equal(hashA, hashB), nil
+}
+
+// computeWebBundlePayloadSha512 computes the SHA-512 hash of the web bundle bytes after the integrity block,
+// if any, of the given file.
+func computeWebBundlePayloadSha512(bundleFile *os.File) ([]byte, error) {
+	offset, err := IntegrityBlockLength(bundleFile)
+	if err != nil {
+		return nil, err
+	}
+	return ComputeWebBundleSha512(bundleFile, offset)
+}
+
+// ComputeWebBundleHash computes the hash over the given web bundle file starting from `offset` with the given
+// hash algorithm. The zero value of `crypto.Hash` selects SHA-512. Only SHA-512 is used for the integrity block:
+// signing and verification, e.g. `SignBundleFile`, `SignPrecomputedHash` and `VerifyBundleFile`, always hash the
+// web bundle with SHA-512, as the integrity block specification doesn't define any other algorithm. Other
+// algorithms are meant for comparing against hashes distributed out of band, see
+// `VerifyWebBundleHashWithAlgorithm`.
+func ComputeWebBundleHash(bundleFile io.ReadSeeker, offset int64, hash crypto.Hash) ([]byte, error) {
 	// Move the file pointer to the start of the web bundle bytes.
-	bundleFile.Seek(offset, io.SeekStart)
+	if _, err := bundleFile.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return computeWebBundleHashStream(bundleFile, hash)
+}
+
+// ComputeWebBundleSha512Stream computes the SHA-512 hash over everything read from the given reader. Unlike
+// `ComputeWebBundleSha512` it doesn't need to seek, so it works with pipes and network streams as long as
+// the reader is already positioned at the start of the web bundle bytes.
+func ComputeWebBundleSha512Stream(r io.Reader) ([]byte, error) {
+	return computeWebBundleHashStream(r, crypto.SHA512)
+}
+
+// computeWebBundleHashStream computes the hash over everything read from the given reader with the given hash
+// algorithm, the zero value selecting SHA-512.
+func computeWebBundleHashStream(r io.Reader, hash crypto.Hash) ([]byte, error) {
+	if hash == 0 {
+		hash = crypto.SHA512
+	}
+	if !hash.Available() {
+		return nil, fmt.Errorf("integrityblock: Hash algorithm %v is not available.", hash)
+	}
+
+	h := hash.New()
 
 	// io.Copy() will do chunked read/write under the hood
-	_, err := io.Copy(h, bundleFile)
+	if _, err := io.Copy(h, r); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// ComputeWebBundleSha512Bounded computes the SHA-512 hash over exactly `length` bytes of `r` starting from
+// `offset`, e.g. when the web bundle is embedded in a larger container. Nothing after the given section is
+// hashed, and it fails if `r` ends before the end of the section.
+func ComputeWebBundleSha512Bounded(r io.ReaderAt, offset, length int64) ([]byte, error) {
+	if offset < 0 || length < 0 {
+		return nil, fmt.Errorf("integrityblock: Invalid web bundle section at offset %d of %d bytes.", offset, length)
+	}
+
+	h := sha512.New()
+	n, err := io.Copy(h, io.NewSectionReader(r, offset, length))
 	if err != nil {
 		return nil, err
 	}
+	if n != length {
+		return nil, fmt.Errorf("%w Web bundle section at offset %d should be %d bytes, got %d bytes.", ErrTruncatedBundle, offset, length, n)
+	}
 	return h.Sum(nil), nil
 }
 
+// hashingChunkSize is the size of the chunks in which the web bundle is read when hashing it chunk by chunk.
+const hashingChunkSize = 32 * 1024
+
+// ComputeWebBundleSha512Context is like `ComputeWebBundleSha512`, but checks between the chunks whether the
+// context is done, so that hashing large web bundles can be cancelled. It returns ctx.Err() if cancelled.
+func ComputeWebBundleSha512Context(ctx context.Context, bundleFile io.ReadSeeker, offset int64) ([]byte, error) {
+	// Move the file pointer to the start of the web bundle bytes.
+	if _, err := bundleFile.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	h := sha512.New()
+	buf := make([]byte, hashingChunkSize)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+
+		n, err := bundleFile.Read(buf)
+		h.Write(buf[:n])
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return h.Sum(nil), nil
+}
+
+// progressInterval is the number of hashed bytes between the calls of the progress callback of
+// `ComputeWebBundleSha512Progress`.
+const progressInterval = 4 << 20
+
+// ComputeWebBundleSha512Progress is like `ComputeWebBundleSha512`, but calls `onProgress` with the number of
+// bytes hashed so far every 4 MiB and once more with the total when the whole web bundle has been hashed, e.g.
+// for showing a progress bar while hashing large web bundles. A nil `onProgress` reports nothing.
+func ComputeWebBundleSha512Progress(r io.ReadSeeker, offset int64, onProgress func(bytesHashed int64)) ([]byte, error) {
+	if onProgress == nil {
+		onProgress = func(int64) {}
+	}
+
+	// Move the file pointer to the start of the web bundle bytes.
+	if _, err := r.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	pr := &progressReader{r: r, onProgress: onProgress}
+	webBundleHash, err := computeWebBundleHashStream(pr, crypto.SHA512)
+	if err != nil {
+		return nil, err
+	}
+	if pr.bytesRead != pr.lastReported || pr.bytesRead == 0 {
+		onProgress(pr.bytesRead)
+	}
+	return webBundleHash, nil
+}
+
+// progressReader counts the bytes read through it and calls `onProgress` every `progressInterval` bytes.
+type progressReader struct {
+	r                       io.Reader
+	onProgress              func(bytesRead int64)
+	bytesRead, lastReported int64
+}
+
+func (pr *progressReader) Read(p []byte) (int, error) {
+	n, err := pr.r.Read(p)
+	pr.bytesRead += int64(n)
+	if pr.bytesRead-pr.lastReported >= progressInterval {
+		pr.onProgress(pr.bytesRead)
+		pr.lastReported = pr.bytesRead
+	}
+	return n, err
+}
+
 // GenerateDataToBeSigned creates a bytes array containing the payload of which the signature of the web bundle will be calculated.
 // The order must be the following, where the lengths are represented as 64 bit big-endian integers:
 // (1) length of the web bundle hash, (2) web bundle hash, (3) length of the serialized integrity-block
@@ -187,20 +1073,41 @@ func GenerateDataToBeSigned(webBundleHash, integrityBlockBytes []byte, signature
 	var attributesBytesBuf bytes.Buffer
 	enc := cbor.NewEncoder(&attributesBytesBuf)
 	if err := signatureAttributes.cborBytes(enc); err != nil {
-		return nil, fmt.Errorf("integrityblock: Failed to encode signature attributes: %v", err)
+		return nil, err
 	}
 	attributesBytes := attributesBytesBuf.Bytes()
 
 	var buf bytes.Buffer
-	binary.Write(&buf, binary.BigEndian, uint64(len(webBundleHash)))
-	buf.Write(webBundleHash)
-	binary.Write(&buf, binary.BigEndian, uint64(len(integrityBlockBytes)))
-	buf.Write(integrityBlockBytes)
-	binary.Write(&buf, binary.BigEndian, uint64(len(attributesBytes)))
-	buf.Write(attributesBytes)
+	writeFramedWebBundleHash(&buf, webBundleHash)
+	writeFramedIntegrityBlock(&buf, integrityBlockBytes)
+	writeFramedSignatureAttributes(&buf, attributesBytes)
 	return buf.Bytes(), nil
 }
 
+// writeLengthPrefixed writes the length of the element as a 64 bit big-endian integer followed by the element,
+// which is how every element of the signed payload is framed.
+func writeLengthPrefixed(buf *bytes.Buffer, element []byte) {
+	var lengthBytes [8]byte
+	binary.BigEndian.PutUint64(lengthBytes[:], uint64(len(element)))
+	buf.Write(lengthBytes[:])
+	buf.Write(element)
+}
+
+// writeFramedWebBundleHash writes the first element of the signed payload, the web bundle hash.
+func writeFramedWebBundleHash(buf *bytes.Buffer, webBundleHash []byte) {
+	writeLengthPrefixed(buf, webBundleHash)
+}
+
+// writeFramedIntegrityBlock writes the second element of the signed payload, the serialized integrity block.
+func writeFramedIntegrityBlock(buf *bytes.Buffer, integrityBlockBytes []byte) {
+	writeLengthPrefixed(buf, integrityBlockBytes)
+}
+
+// writeFramedSignatureAttributes writes the third element of the signed payload, the serialized signature attributes.
+func writeFramedSignatureAttributes(buf *bytes.Buffer, attributesBytes []byte) {
+	writeLengthPrefixed(buf, attributesBytes)
+}
+
 // GenerateSignatureAttributesWithPublicKey generates the basis for the map for signature attributes containing the public key.
 func GenerateSignatureAttributesWithPublicKey(ed25519publicKey ed25519.PublicKey) SignatureAttributesMap {
 	return SignatureAttributesMap{Ed25519publicKeyAttributeName: []byte(ed25519publicKey)}
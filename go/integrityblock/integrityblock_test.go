@@ -2,11 +2,21 @@ package integrityblock
 
 import (
 	"bytes"
+	"context"
+	"crypto"
 	"crypto/ed25519"
 	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/hex"
+	"errors"
+	"io"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/WICG/webpackage/go/internal/cbor"
 	"github.com/WICG/webpackage/go/internal/testhelper"
@@ -80,6 +90,23 @@ func TestIntegritySignature(t *testing.T) {
 	}
 }
 
+func TestIntegritySignatureAttributeAccessors(t *testing.T) {
+	integritySignature := &IntegritySignature{
+		SignatureAttributes: SignatureAttributesMap{Ed25519publicKeyAttributeName: []byte("publickey"), "hello": []byte("world")},
+		Signature:           []byte("signature"),
+	}
+
+	if got, ok := integritySignature.Ed25519PublicKey(); !ok || !bytes.Equal(got, []byte("publickey")) {
+		t.Errorf("integrityblock: got: %q, %v\nwant: \"publickey\", true", got, ok)
+	}
+	if got, ok := integritySignature.Attribute("hello"); !ok || !bytes.Equal(got, []byte("world")) {
+		t.Errorf("integrityblock: got: %q, %v\nwant: \"world\", true", got, ok)
+	}
+	if _, ok := integritySignature.EcdsaP256SHA256PublicKey(); ok {
+		t.Error("ECDSA public key should not be present.")
+	}
+}
+
 func TestComputeWebBundleSha512(t *testing.T) {
 	bundleFile, err := os.Open("./testfile.wbn")
 	if err != nil {
@@ -101,6 +128,208 @@ func TestComputeWebBundleSha512(t *testing.T) {
 	}
 }
 
+func TestComputeWebBundleHash(t *testing.T) {
+	webBundleBytes := []byte("webbundle")
+	bundle := bytes.NewReader(append([]byte("prefix"), webBundleBytes...))
+
+	got, err := ComputeWebBundleHash(bundle, int64(len("prefix")), crypto.SHA256)
+	if err != nil {
+		t.Fatalf("ComputeWebBundleHash. err: %v", err)
+	}
+	want := sha256.Sum256(webBundleBytes)
+	if !bytes.Equal(got, want[:]) {
+		t.Errorf("integrityblock: got: %x\nwant: %x", got, want)
+	}
+
+	// The zero value defaults to SHA-512.
+	got, err = ComputeWebBundleHash(bundle, int64(len("prefix")), 0)
+	if err != nil {
+		t.Fatalf("ComputeWebBundleHash. err: %v", err)
+	}
+	if wantSha512 := sha512.Sum512(webBundleBytes); !bytes.Equal(got, wantSha512[:]) {
+		t.Errorf("integrityblock: got: %x\nwant: %x", got, wantSha512)
+	}
+
+	if _, err := ComputeWebBundleHash(bundle, 0, crypto.MD4); err == nil {
+		t.Error("ComputeWebBundleHash should fail with an unavailable hash algorithm.")
+	}
+}
+
+func TestCompareWebBundlePayloads(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal("Failed to generate test keys")
+	}
+	dir := t.TempDir()
+	signedBundlePath := filepath.Join(dir, "signed.wbn")
+	if err := SignBundleFile("./testfile.wbn", signedBundlePath, NewParsedEd25519KeySigningStrategy(priv)); err != nil {
+		t.Fatal(err)
+	}
+
+	unsignedBundle, err := os.ReadFile("./testfile.wbn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Flip a bit in the web bundle bytes, keeping the trailing length intact.
+	unsignedBundle[len(unsignedBundle)-10] ^= 0x01
+	modifiedBundlePath := filepath.Join(dir, "modified.wbn")
+	if err := os.WriteFile(modifiedBundlePath, unsignedBundle, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	openHelper := func(path string) *os.File {
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { f.Close() })
+		return f
+	}
+
+	same, err := CompareWebBundlePayloads(openHelper("./testfile.wbn"), openHelper(signedBundlePath))
+	if err != nil {
+		t.Fatalf("CompareWebBundlePayloads. err: %v", err)
+	}
+	if !same {
+		t.Error("Signing should not change the web bundle payload.")
+	}
+
+	same, err = CompareWebBundlePayloads(openHelper(signedBundlePath), openHelper(modifiedBundlePath))
+	if err != nil {
+		t.Fatalf("CompareWebBundlePayloads. err: %v", err)
+	}
+	if same {
+		t.Error("Modified web bundle payload should not compare equal.")
+	}
+
+	emptyFile := createTempFileHelper(t, []byte{})
+	defer emptyFile.Close()
+	if _, err := CompareWebBundlePayloads(openHelper(signedBundlePath), emptyFile); err == nil {
+		t.Error("CompareWebBundlePayloads should fail with a file too small to be a web bundle.")
+	}
+}
+
+func TestComputeWebBundleSha512Stream(t *testing.T) {
+	bundleFile, err := os.Open("./testfile.wbn")
+	if err != nil {
+		t.Fatal("Failed to open the test file")
+	}
+	defer bundleFile.Close()
+
+	want, err := ComputeWebBundleSha512(bundleFile, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Hide the io.Seeker implementation of the file to simulate a stream.
+	bundleFile.Seek(0, io.SeekStart)
+	got, err := ComputeWebBundleSha512Stream(io.MultiReader(bundleFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("integrityblock: got: %s\nwant: %s", hex.EncodeToString(got), hex.EncodeToString(want))
+	}
+}
+
+func TestComputeWebBundleSha512Bounded(t *testing.T) {
+	webBundleBytes, err := os.ReadFile("./testfile.wbn")
+	if err != nil {
+		t.Fatal("Failed to read the test file")
+	}
+	// The web bundle embedded in a container with a header and trailing metadata.
+	container := append(append([]byte("header"), webBundleBytes...), []byte("trailing metadata")...)
+
+	got, err := ComputeWebBundleSha512Bounded(bytes.NewReader(container), int64(len("header")), int64(len(webBundleBytes)))
+	if err != nil {
+		t.Fatalf("ComputeWebBundleSha512Bounded. err: %v", err)
+	}
+	if want := sha512Helper(webBundleBytes); !bytes.Equal(got, want) {
+		t.Errorf("integrityblock: got: %s\nwant: %s", hex.EncodeToString(got), hex.EncodeToString(want))
+	}
+
+	if _, err := ComputeWebBundleSha512Bounded(bytes.NewReader(container), int64(len("header")), int64(len(container))); !errors.Is(err, ErrTruncatedBundle) {
+		t.Errorf("integrityblock: got err: %v\nwant: %v", err, ErrTruncatedBundle)
+	}
+	if _, err := ComputeWebBundleSha512Bounded(bytes.NewReader(container), -1, 1); err == nil {
+		t.Error("ComputeWebBundleSha512Bounded should fail with a negative offset.")
+	}
+}
+
+func TestComputeWebBundleSha512Context(t *testing.T) {
+	bundleFile, err := os.Open("./testfile.wbn")
+	if err != nil {
+		t.Fatal("Failed to open the test file")
+	}
+	defer bundleFile.Close()
+
+	want, err := ComputeWebBundleSha512(bundleFile, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ComputeWebBundleSha512Context(context.Background(), bundleFile, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("integrityblock: got: %s\nwant: %s", hex.EncodeToString(got), hex.EncodeToString(want))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := ComputeWebBundleSha512Context(ctx, bundleFile, 0); !errors.Is(err, context.Canceled) {
+		t.Errorf("integrityblock: got err: %v\nwant: %v", err, context.Canceled)
+	}
+}
+
+func TestComputeWebBundleSha512Progress(t *testing.T) {
+	webBundleBytes := bytes.Repeat([]byte{0x01}, 2*progressInterval+100)
+	r := bytes.NewReader(webBundleBytes)
+
+	var reported []int64
+	got, err := ComputeWebBundleSha512Progress(r, 0, func(bytesHashed int64) {
+		reported = append(reported, bytesHashed)
+	})
+	if err != nil {
+		t.Fatalf("ComputeWebBundleSha512Progress. err: %v", err)
+	}
+	if want := sha512Helper(webBundleBytes); !bytes.Equal(got, want) {
+		t.Errorf("integrityblock: got: %x\nwant: %x", got, want)
+	}
+
+	want := []int64{progressInterval, 2 * progressInterval, int64(len(webBundleBytes))}
+	if len(reported) != len(want) {
+		t.Fatalf("integrityblock: got progress: %v\nwant: %v", reported, want)
+	}
+	for i := range want {
+		if reported[i] != want[i] {
+			t.Errorf("integrityblock: got progress: %v\nwant: %v", reported, want)
+			break
+		}
+	}
+
+	// The total is reported even if nothing was hashed.
+	reported = nil
+	if _, err := ComputeWebBundleSha512Progress(r, int64(len(webBundleBytes)), func(bytesHashed int64) {
+		reported = append(reported, bytesHashed)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(reported) != 1 || reported[0] != 0 {
+		t.Errorf("integrityblock: got progress: %v\nwant: [0]", reported)
+	}
+
+	// A nil callback means no progress reporting.
+	got, err = ComputeWebBundleSha512Progress(r, 0, nil)
+	if err != nil {
+		t.Fatalf("ComputeWebBundleSha512Progress with a nil callback. err: %v", err)
+	}
+	if want := sha512Helper(webBundleBytes); !bytes.Equal(got, want) {
+		t.Errorf("integrityblock: got: %x\nwant: %x", got, want)
+	}
+}
+
 func TestGenerateDataToBeSigned(t *testing.T) {
 	signatureAttributes := SignatureAttributesMap{"key": []byte("value")}
 
@@ -137,6 +366,32 @@ func TestGenerateDataToBeSigned(t *testing.T) {
 	}
 }
 
+func TestSignedPayloadFraming(t *testing.T) {
+	testCases := []struct {
+		name  string
+		write func(buf *bytes.Buffer, element []byte)
+	}{
+		{name: "web bundle hash", write: writeFramedWebBundleHash},
+		{name: "integrity block", write: writeFramedIntegrityBlock},
+		{name: "signature attributes", write: writeFramedSignatureAttributes},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tc.write(&buf, []byte("element"))
+			if got, want := hex.EncodeToString(buf.Bytes()), "0000000000000007"+hex.EncodeToString([]byte("element")); got != want {
+				t.Errorf("integrityblock: got: %s\nwant: %s", got, want)
+			}
+
+			buf.Reset()
+			tc.write(&buf, nil)
+			if got, want := hex.EncodeToString(buf.Bytes()), "0000000000000000"; got != want {
+				t.Errorf("integrityblock: got: %s\nwant: %s", got, want)
+			}
+		})
+	}
+}
+
 func TestCborBytesForSignatureAttributesMap(t *testing.T) {
 	signatureAttributes := SignatureAttributesMap{"key": []byte("value")}
 
@@ -157,6 +412,116 @@ func TestCborBytesForSignatureAttributesMap(t *testing.T) {
 	}
 }
 
+// failAfterWriterHelper is an io.Writer which fails once the given number of bytes has been written.
+type failAfterWriterHelper struct {
+	remaining int
+}
+
+func (w *failAfterWriterHelper) Write(p []byte) (int, error) {
+	if len(p) > w.remaining {
+		return 0, errors.New("write failed")
+	}
+	w.remaining -= len(p)
+	return len(p), nil
+}
+
+func TestCborEncodeErrors(t *testing.T) {
+	integritySignature := &IntegritySignature{
+		SignatureAttributes: SignatureAttributesMap{Ed25519publicKeyAttributeName: []byte("publickey")},
+		Signature:           []byte("signature"),
+	}
+	var buf bytes.Buffer
+	if err := integritySignature.SignatureAttributes.cborBytes(cbor.NewEncoder(&buf)); err != nil {
+		t.Fatal(err)
+	}
+	attributesLen := buf.Len()
+
+	testCases := []struct {
+		bytesBeforeFailure int
+		wantField          string
+	}{
+		{bytesBeforeFailure: 0, wantField: "integrity signature array header"},
+		{bytesBeforeFailure: 1, wantField: "signature attributes"},
+		{bytesBeforeFailure: 1 + attributesLen, wantField: "signature"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.wantField, func(t *testing.T) {
+			err := integritySignature.cborBytes(cbor.NewEncoder(&failAfterWriterHelper{remaining: tc.bytesBeforeFailure}))
+			if !errors.Is(err, ErrCborEncode) {
+				t.Fatalf("integrityblock: got err: %v\nwant: %v", err, ErrCborEncode)
+			}
+			if !strings.Contains(err.Error(), "Failed to encode "+tc.wantField+":") {
+				t.Errorf("integrityblock: error should name the field %q, got: %v", tc.wantField, err)
+			}
+		})
+	}
+}
+
+func TestCborBytesWithNilSignature(t *testing.T) {
+	integrityBlock := generateEmptyIntegrityBlock()
+	integrityBlock.addNewSignatureToIntegrityBlock(SignatureAttributesMap{Ed25519publicKeyAttributeName: []byte("publickey")}, []byte("signature"))
+	integrityBlock.SignatureStack = append(integrityBlock.SignatureStack, nil)
+
+	got, err := integrityBlock.CborBytes()
+	if err == nil {
+		t.Fatalf("CborBytes should fail with a nil signature, got: %x", got)
+	}
+	if !strings.Contains(err.Error(), "signatureStack[1]") {
+		t.Errorf("integrityblock: error should identify the nil signature, got: %v", err)
+	}
+}
+
+func TestSignatureAttributesEncodingIsDeterministic(t *testing.T) {
+	signatureAttributes := SignatureAttributesMap{}
+	for _, key := range []string{"z", "b", "aa", "ab", "c", "ba", Ed25519publicKeyAttributeName, "hello", "a"} {
+		signatureAttributes[key] = []byte(key)
+	}
+
+	var want []byte
+	for i := 0; i < 20; i++ {
+		var buf bytes.Buffer
+		if err := signatureAttributes.cborBytes(cbor.NewEncoder(&buf)); err != nil {
+			t.Fatal(err)
+		}
+		if err := cbor.Deterministic(buf.Bytes()); err != nil {
+			t.Fatalf("Signature attributes should be deterministic. err: %v", err)
+		}
+		if want == nil {
+			want = buf.Bytes()
+		} else if !bytes.Equal(buf.Bytes(), want) {
+			t.Fatalf("integrityblock: got: %x\nwant: %x", buf.Bytes(), want)
+		}
+	}
+}
+
+func TestSigningSameAttributesTwiceIsByteIdentical(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal("Failed to generate test keys")
+	}
+	webBundleHash := sha512Helper([]byte("webbundle"))
+
+	var want []byte
+	for i := 0; i < 2; i++ {
+		integrityBlock := generateEmptyIntegrityBlock()
+		integritySignature, err := SignIntegrityBlock(integrityBlock, NewParsedEd25519KeySigningStrategy(priv), webBundleHash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		AppendSignature(integrityBlock, integritySignature)
+
+		got, err := integrityBlock.CborBytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want == nil {
+			want = got
+		} else if !bytes.Equal(got, want) {
+			t.Errorf("integrityblock: got: %x\nwant: %x", got, want)
+		}
+	}
+}
+
 func TestIntegrityBlockGeneratedWithTheToolIsDeterministic(t *testing.T) {
 	integrityBlock := generateEmptyIntegrityBlock()
 	integrityBlockBytes, err := integrityBlock.CborBytes()
@@ -331,18 +696,1155 @@ func TestSignAndAddNewSignatureWithExistingSignature(t *testing.T) {
 	}
 }
 
-func bytesToCborAndToReadableStringHelper(bts []byte) (string, error) {
-	var buf bytes.Buffer
-	enc := cbor.NewEncoder(&buf)
+func TestSignAndAddNewSignatureWithMismatchingPublicKey(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal("Failed to generate test keys")
+	}
+	otherPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal("Failed to generate test keys")
+	}
 
-	err := enc.EncodeByteString(bts)
+	ibs := IntegrityBlockSigner{
+		SigningStrategy: NewParsedEd25519KeySigningStrategy(priv),
+		WebBundleHash:   make([]byte, 64),
+		IntegrityBlock:  generateEmptyIntegrityBlock(),
+	}
+	publicKey, err := ibs.SigningStrategy.GetPublicKey()
 	if err != nil {
-		return "", err
+		t.Fatal(err)
 	}
 
-	cborAsString, err := testhelper.CborBinaryToReadableString(buf.Bytes())
+	if err := ibs.SignAndAddNewSignature(publicKey, GenerateSignatureAttributesWithPublicKey(otherPub)); err == nil {
+		t.Error("SignAndAddNewSignature should fail when the attributes contain another public key.")
+	}
+	if err := ibs.SignAndAddNewSignature(publicKey, SignatureAttributesMap{"hello": []byte("world")}); err == nil {
+		t.Error("SignAndAddNewSignature should fail when the attributes lack the public key.")
+	}
+	// Signing with the private key of another public key is caught by the verification after signing.
+	if err := ibs.SignAndAddNewSignature(otherPub, GenerateSignatureAttributesWithPublicKey(otherPub)); err == nil {
+		t.Error("SignAndAddNewSignature should fail when the public key doesn't belong to the signing key.")
+	}
+	if len(ibs.IntegrityBlock.SignatureStack) != 0 {
+		t.Error("Failed signing should not modify the signature stack.")
+	}
+}
+
+func TestCheckSignerPublicKeyAttribute(t *testing.T) {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
 	if err != nil {
-		return "", err
+		t.Fatal("Failed to generate test keys")
+	}
+	otherPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal("Failed to generate test keys")
+	}
+
+	if err := checkSignerPublicKeyAttribute(GenerateSignatureAttributesWithPublicKey(pub), pub); err != nil {
+		t.Errorf("integrityblock: unexpected error: %v", err)
+	}
+	if err := checkSignerPublicKeyAttribute(GenerateSignatureAttributesWithPublicKey(otherPub), pub); err == nil {
+		t.Error("Mismatching public key attribute should be rejected.")
+	}
+	if err := checkSignerPublicKeyAttribute(SignatureAttributesMap{}, pub); err == nil {
+		t.Error("Missing public key attribute should be rejected.")
+	}
+}
+
+func TestSignPrecomputedHash(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal("Failed to generate test keys")
+	}
+	signer := NewParsedEd25519KeySigningStrategy(priv)
+	webBundleHash := sha512Helper([]byte("webbundle"))
+
+	integritySignature, err := SignPrecomputedHash(webBundleHash, signer)
+	if err != nil {
+		t.Fatalf("SignPrecomputedHash. err: %v", err)
+	}
+	ok, err := VerifyIntegritySignature(generateEmptyIntegrityBlock(), integritySignature, webBundleHash)
+	if err != nil || !ok {
+		t.Errorf("integrityblock: got ok: %v, err: %v", ok, err)
+	}
+
+	for _, invalidHash := range [][]byte{nil, webBundleHash[:32], append(webBundleHash, 0x00)} {
+		if _, err := SignPrecomputedHash(invalidHash, signer); err == nil {
+			t.Errorf("SignPrecomputedHash should fail with a %d byte hash.", len(invalidHash))
+		}
+	}
+}
+
+func TestNewSignedIntegrityBlock(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal("Failed to generate test keys")
+	}
+	webBundleHash := sha512Helper([]byte("webbundle"))
+
+	integrityBlock, err := NewSignedIntegrityBlock(webBundleHash, NewParsedEd25519KeySigningStrategy(priv))
+	if err != nil {
+		t.Fatalf("NewSignedIntegrityBlock. err: %v", err)
+	}
+	if len(integrityBlock.SignatureStack) != 1 || !bytes.Equal(integrityBlock.SignatureStack[0].SignatureAttributes[Ed25519publicKeyAttributeName], pub) {
+		t.Fatalf("integrityblock: got: %s", integrityBlock.DiagnosticString())
+	}
+
+	result, err := VerifyIntegrityBlock(integrityBlock, webBundleHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Valid {
+		t.Errorf("integrityblock: got result: %+v", result)
+	}
+
+	if _, err := NewSignedIntegrityBlock(webBundleHash, failingSignerHelper{pub}); err == nil {
+		t.Error("NewSignedIntegrityBlock should fail when the signer fails.")
+	}
+}
+
+func TestSignIntegrityBlock(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal("Failed to generate test keys")
+	}
+
+	bundleFile, err := os.Open("./testfile.wbn")
+	if err != nil {
+		t.Fatal("Failed to open the test file")
+	}
+	defer bundleFile.Close()
+
+	webBundleHash, err := ComputeWebBundleSha512(bundleFile, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	integrityBlock := generateEmptyIntegrityBlock()
+	integritySignature, err := SignIntegrityBlock(integrityBlock, NewParsedEd25519KeySigningStrategy(priv), webBundleHash)
+	if err != nil {
+		t.Fatalf("SignIntegrityBlock. err: %v", err)
+	}
+
+	if len(integrityBlock.SignatureStack) != 0 {
+		t.Error("SignIntegrityBlock should not modify the integrity block.")
+	}
+	if !bytes.Equal(integritySignature.SignatureAttributes[Ed25519publicKeyAttributeName], pub) {
+		t.Error("Signature attributes should contain the public key of the signer.")
+	}
+
+	// The verifier reconstructs the payload from the integrity block without the new signature.
+	integrityBlockBytes, err := integrityBlock.CborBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dataToBeSigned, err := GenerateDataToBeSigned(webBundleHash, integrityBlockBytes, integritySignature.SignatureAttributes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ed25519.Verify(pub, dataToBeSigned, integritySignature.Signature) {
+		t.Error("Signature should be verifiable with the public key over the reconstructed payload.")
+	}
+
+	integrityBlock.addNewSignatureToIntegrityBlock(integritySignature.SignatureAttributes, integritySignature.Signature)
+	integrityBlockBytes, err = integrityBlock.CborBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cbor.Deterministic(integrityBlockBytes); err != nil {
+		t.Errorf("Signed integrity block should be deterministic. err: %v", err)
+	}
+}
+
+func TestObtainIntegrityBlockWithAlreadySignedBundle(t *testing.T) {
+	webBundleBytes, err := os.ReadFile("./testfile.wbn")
+	if err != nil {
+		t.Fatal("Failed to read the test file")
+	}
+	integrityBlockBytes, err := generateEmptyIntegrityBlock().CborBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bundleFile := createTempFileHelper(t, append(integrityBlockBytes, webBundleBytes...))
+	defer bundleFile.Close()
+
+	_, offset, err := ObtainIntegrityBlock(bundleFile)
+	if !errors.Is(err, ErrBundleAlreadySigned) {
+		t.Errorf("integrityblock: got err: %v\nwant: %v", err, ErrBundleAlreadySigned)
+	}
+	if offset != int64(len(integrityBlockBytes)) {
+		t.Errorf("integrityblock: got offset: %d\nwant: %d", offset, len(integrityBlockBytes))
+	}
+}
+
+func TestObtainIntegrityBlockFrom(t *testing.T) {
+	webBundleBytes, err := os.ReadFile("./testfile.wbn")
+	if err != nil {
+		t.Fatal("Failed to read the test file")
+	}
+
+	integrityBlock, offset, err := ObtainIntegrityBlockFrom(bytes.NewReader(webBundleBytes), int64(len(webBundleBytes)), nil)
+	if err != nil {
+		t.Fatalf("ObtainIntegrityBlockFrom. err: %v", err)
+	}
+	if offset != 0 || len(integrityBlock.SignatureStack) != 0 {
+		t.Errorf("integrityblock: got offset %d and %d signatures, want a new empty integrity block", offset, len(integrityBlock.SignatureStack))
+	}
+
+	integrityBlockBytes, err := generateEmptyIntegrityBlock().CborBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signedBundle := append(integrityBlockBytes, webBundleBytes...)
+	_, offset, err = ObtainIntegrityBlockFrom(bytes.NewReader(signedBundle), int64(len(signedBundle)), nil)
+	if !errors.Is(err, ErrBundleAlreadySigned) {
+		t.Errorf("integrityblock: got err: %v\nwant: %v", err, ErrBundleAlreadySigned)
+	}
+	if offset != int64(len(integrityBlockBytes)) {
+		t.Errorf("integrityblock: got offset: %d\nwant: %d", offset, len(integrityBlockBytes))
+	}
+
+	if _, _, err := ObtainIntegrityBlockFrom(bytes.NewReader(webBundleBytes), 4, nil); !errors.Is(err, ErrTruncatedBundle) {
+		t.Errorf("integrityblock: got err: %v\nwant: %v", err, ErrTruncatedBundle)
+	}
+
+	// A reader which is not an io.ReaderAt has the trailing length read by seeking.
+	seekOnly := struct{ io.ReadSeeker }{bytes.NewReader(signedBundle)}
+	_, offset, err = ObtainIntegrityBlockFrom(seekOnly, int64(len(signedBundle)), nil)
+	if !errors.Is(err, ErrBundleAlreadySigned) {
+		t.Errorf("integrityblock: got err: %v\nwant: %v", err, ErrBundleAlreadySigned)
+	}
+	if offset != int64(len(integrityBlockBytes)) {
+		t.Errorf("integrityblock: got offset: %d\nwant: %d", offset, len(integrityBlockBytes))
+	}
+}
+
+func TestObtainIntegrityBlockInfo(t *testing.T) {
+	webBundleBytes, err := os.ReadFile("./testfile.wbn")
+	if err != nil {
+		t.Fatal("Failed to read the test file")
+	}
+	integrityBlock := generateEmptyIntegrityBlock()
+	integrityBlock.addNewSignatureToIntegrityBlock(SignatureAttributesMap{Ed25519publicKeyAttributeName: []byte("publickey")}, []byte("signature"))
+	integrityBlockBytes, err := integrityBlock.CborBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unsignedBundleFile := createTempFileHelper(t, webBundleBytes)
+	defer unsignedBundleFile.Close()
+	info, err := ObtainIntegrityBlockInfo(unsignedBundleFile)
+	if err != nil {
+		t.Fatalf("ObtainIntegrityBlockInfo. err: %v", err)
+	}
+	if info.PreExisting || info.PayloadOffset != 0 || len(info.Block.SignatureStack) != 0 {
+		t.Errorf("integrityblock: got info: %+v, want a new empty integrity block", info)
+	}
+
+	signedBundleFile := createTempFileHelper(t, append(integrityBlockBytes, webBundleBytes...))
+	defer signedBundleFile.Close()
+	info, err = ObtainIntegrityBlockInfo(signedBundleFile)
+	if err != nil {
+		t.Fatalf("ObtainIntegrityBlockInfo. err: %v", err)
+	}
+	if !info.PreExisting || info.PayloadOffset != int64(len(integrityBlockBytes)) {
+		t.Errorf("integrityblock: got info: %+v, want the existing integrity block of %d bytes", info, len(integrityBlockBytes))
+	}
+	if len(info.Block.SignatureStack) != 1 || string(info.Block.SignatureStack[0].Signature) != "signature" {
+		t.Error("Info should contain the existing integrity block parsed.")
+	}
+}
+
+func TestObtainIntegrityBlockWithTooLargeWebBundleLength(t *testing.T) {
+	bundleFile := createTempFileHelper(t, []byte{0, 0, 0, 0, 0, 0, 0x01, 0})
+	defer bundleFile.Close()
+
+	if _, _, err := ObtainIntegrityBlock(bundleFile); !errors.Is(err, ErrNegativeIntegrityBlockLength) {
+		t.Errorf("integrityblock: got err: %v\nwant: %v", err, ErrNegativeIntegrityBlockLength)
+	}
+}
+
+func TestIntegrityBlockLength(t *testing.T) {
+	webBundleBytes, err := os.ReadFile("./testfile.wbn")
+	if err != nil {
+		t.Fatal("Failed to read the test file")
+	}
+	integrityBlockBytes, err := generateEmptyIntegrityBlock().CborBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unsignedBundleFile := createTempFileHelper(t, webBundleBytes)
+	defer unsignedBundleFile.Close()
+	if got, err := IntegrityBlockLength(unsignedBundleFile); err != nil || got != 0 {
+		t.Errorf("integrityblock: got: %d, %v\nwant: 0", got, err)
+	}
+
+	signedBundleFile := createTempFileHelper(t, append(integrityBlockBytes, webBundleBytes...))
+	defer signedBundleFile.Close()
+	if got, err := IntegrityBlockLength(signedBundleFile); err != nil || got != int64(len(integrityBlockBytes)) {
+		t.Errorf("integrityblock: got: %d, %v\nwant: %d", got, err, len(integrityBlockBytes))
+	}
+
+	invalidBundleFile := createTempFileHelper(t, []byte{0, 0, 0, 0, 0, 0, 0x01, 0})
+	defer invalidBundleFile.Close()
+	if _, err := IntegrityBlockLength(invalidBundleFile); !errors.Is(err, ErrNegativeIntegrityBlockLength) {
+		t.Errorf("integrityblock: got err: %v\nwant: %v", err, ErrNegativeIntegrityBlockLength)
+	}
+}
+
+func TestObtainIntegrityBlockFromWithLengthReader(t *testing.T) {
+	webBundleBytes, err := os.ReadFile("./testfile.wbn")
+	if err != nil {
+		t.Fatal("Failed to read the test file")
+	}
+	integrityBlockBytes, err := generateEmptyIntegrityBlock().CborBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A framing which appends a 4-byte footer after the web bundle's trailing length.
+	footer := []byte("wbnx")
+	readLengthBeforeFooter := func(r io.ReaderAt, size int64) (int64, error) {
+		webBundleLen, err := ReadWebBundlePayloadLengthAt(r, size-int64(len(footer)))
+		return webBundleLen + int64(len(footer)), err
+	}
+
+	unsignedBundle := append(bytes.Clone(webBundleBytes), footer...)
+	if _, offset, err := ObtainIntegrityBlockFrom(bytes.NewReader(unsignedBundle), int64(len(unsignedBundle)), readLengthBeforeFooter); err != nil || offset != 0 {
+		t.Errorf("integrityblock: got: %d, %v\nwant: 0", offset, err)
+	}
+
+	signedBundle := append(append(bytes.Clone(integrityBlockBytes), webBundleBytes...), footer...)
+	if _, offset, err := ObtainIntegrityBlockFrom(bytes.NewReader(signedBundle), int64(len(signedBundle)), readLengthBeforeFooter); !errors.Is(err, ErrBundleAlreadySigned) || offset != int64(len(integrityBlockBytes)) {
+		t.Errorf("integrityblock: got: %d, %v\nwant: %d, %v", offset, err, len(integrityBlockBytes), ErrBundleAlreadySigned)
+	}
+
+	errLength := errors.New("no length")
+	failingLengthReader := func(r io.ReaderAt, size int64) (int64, error) {
+		return 0, errLength
+	}
+	if _, _, err := ObtainIntegrityBlockFrom(bytes.NewReader(unsignedBundle), int64(len(unsignedBundle)), failingLengthReader); !errors.Is(err, errLength) {
+		t.Errorf("integrityblock: got err: %v\nwant: %v", err, errLength)
+	}
+}
+
+func TestReadWebBundlePayloadLengthAt(t *testing.T) {
+	webBundleBytes, err := os.ReadFile("./testfile.wbn")
+	if err != nil {
+		t.Fatal("Failed to read the test file")
+	}
+	integrityBlockBytes, err := generateEmptyIntegrityBlock().CborBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signedBundleBytes := append(integrityBlockBytes, webBundleBytes...)
+
+	got, err := ReadWebBundlePayloadLengthAt(bytes.NewReader(signedBundleBytes), int64(len(signedBundleBytes)))
+	if err != nil {
+		t.Fatalf("ReadWebBundlePayloadLengthAt. err: %v", err)
+	}
+	if got != int64(len(webBundleBytes)) {
+		t.Errorf("integrityblock: got: %d\nwant: %d", got, len(webBundleBytes))
+	}
+
+	if _, err := ReadWebBundlePayloadLengthAt(bytes.NewReader([]byte{0x01}), 1); !errors.Is(err, ErrTruncatedBundle) {
+		t.Errorf("integrityblock: got err: %v\nwant: %v", err, ErrTruncatedBundle)
+	}
+}
+
+func TestIntegrityBlockLengthWithTruncatedBundle(t *testing.T) {
+	testCases := map[string][]byte{
+		"Shorter than the trailing length": {0x00, 0x00, 0x08},
+		"Trailing length of itself only":   {0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x08},
+		"Trailing length below minimum":    append(bytes.Repeat([]byte{0x01}, 20), 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x17),
+	}
+	for name, bundleBytes := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := IntegrityBlockLength(createTempFileHelper(t, bundleBytes)); !errors.Is(err, ErrTruncatedBundle) {
+				t.Errorf("integrityblock: got err: %v\nwant: %v", err, ErrTruncatedBundle)
+			}
+			if _, err := StripIntegrityBlock(bytes.NewReader(bundleBytes)); !errors.Is(err, ErrTruncatedBundle) {
+				t.Errorf("integrityblock: got err: %v\nwant: %v", err, ErrTruncatedBundle)
+			}
+		})
+	}
+}
+
+func TestStripIntegrityBlock(t *testing.T) {
+	webBundleBytes, err := os.ReadFile("./testfile.wbn")
+	if err != nil {
+		t.Fatal("Failed to read the test file")
+	}
+	integrityBlock := generateEmptyIntegrityBlock()
+	integrityBlock.addNewSignatureToIntegrityBlock(SignatureAttributesMap{Ed25519publicKeyAttributeName: []byte("publickey")}, []byte("signature"))
+	integrityBlockBytes, err := integrityBlock.CborBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := StripIntegrityBlock(bytes.NewReader(append(integrityBlockBytes, webBundleBytes...)))
+	if err != nil {
+		t.Fatalf("StripIntegrityBlock. err: %v", err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, webBundleBytes) {
+		t.Error("Stripped web bundle should equal the original unsigned web bundle.")
+	}
+
+	if _, err := StripIntegrityBlock(bytes.NewReader(webBundleBytes)); !errors.Is(err, ErrBundleNotSigned) {
+		t.Errorf("integrityblock: got err: %v\nwant: %v", err, ErrBundleNotSigned)
+	}
+
+	garbage := bytes.Repeat([]byte{0x01}, len(integrityBlockBytes))
+	if _, err := StripIntegrityBlock(bytes.NewReader(append(garbage, webBundleBytes...))); err == nil {
+		t.Error("StripIntegrityBlock should fail when the leading bytes are not an integrity block.")
+	}
+}
+
+func TestSplitSignedBundle(t *testing.T) {
+	webBundleBytes, err := os.ReadFile("./testfile.wbn")
+	if err != nil {
+		t.Fatal("Failed to read the test file")
+	}
+	integrityBlock := generateEmptyIntegrityBlock()
+	integrityBlock.addNewSignatureToIntegrityBlock(SignatureAttributesMap{Ed25519publicKeyAttributeName: []byte("publickey")}, []byte("signature"))
+	integrityBlockBytes, err := integrityBlock.CborBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gotBlock, payload, err := SplitSignedBundle(bytes.NewReader(append(integrityBlockBytes, webBundleBytes...)))
+	if err != nil {
+		t.Fatalf("SplitSignedBundle. err: %v", err)
+	}
+	if !bytes.Equal(gotBlock, integrityBlockBytes) {
+		t.Errorf("integrityblock: got: %x\nwant: %x", gotBlock, integrityBlockBytes)
+	}
+	gotPayload, err := io.ReadAll(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(gotPayload, webBundleBytes) {
+		t.Error("Payload should equal the original unsigned web bundle.")
+	}
+
+	if _, _, err := SplitSignedBundle(bytes.NewReader(webBundleBytes)); !errors.Is(err, ErrBundleNotSigned) {
+		t.Errorf("integrityblock: got err: %v\nwant: %v", err, ErrBundleNotSigned)
+	}
+}
+
+func TestVerifyWebBundleMagic(t *testing.T) {
+	webBundleBytes, err := os.ReadFile("./testfile.wbn")
+	if err != nil {
+		t.Fatal("Failed to read the test file")
+	}
+
+	if err := VerifyWebBundleMagic(bytes.NewReader(webBundleBytes)); err != nil {
+		t.Errorf("VerifyWebBundleMagic. err: %v", err)
+	}
+
+	invalidBundles := map[string][]byte{
+		"Garbage":   bytes.Repeat([]byte{0x01}, len(webBundleBytes)),
+		"Truncated": webBundleBytes[:4],
+		"Empty":     {},
+	}
+	for name, invalidBundle := range invalidBundles {
+		t.Run(name, func(t *testing.T) {
+			if err := VerifyWebBundleMagic(bytes.NewReader(invalidBundle)); !errors.Is(err, ErrInvalidWebBundleMagic) {
+				t.Errorf("integrityblock: got err: %v\nwant: %v", err, ErrInvalidWebBundleMagic)
+			}
+		})
+	}
+}
+
+func TestClone(t *testing.T) {
+	original := generateEmptyIntegrityBlock()
+	original.addNewSignatureToIntegrityBlock(SignatureAttributesMap{Ed25519publicKeyAttributeName: []byte("publickey1")}, []byte("signature1"))
+	original.addNewSignatureToIntegrityBlock(SignatureAttributesMap{Ed25519publicKeyAttributeName: []byte("publickey2")}, []byte("signature2"))
+	originalBytes, err := original.CborBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clone := original.Clone()
+	cloneBytes, err := clone.CborBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(cloneBytes, originalBytes) {
+		t.Errorf("integrityblock: got: %x\nwant: %x", cloneBytes, originalBytes)
+	}
+
+	clone.Magic[0] = 0x00
+	clone.Version[0] = 0x00
+	clone.SignatureStack[0].Signature[0] = 'X'
+	clone.SignatureStack[0].SignatureAttributes[Ed25519publicKeyAttributeName][0] = 'X'
+	clone.SignatureStack[1].SignatureAttributes["hello"] = []byte("world")
+	clone.SignatureStack[1] = nil
+	clone.addNewSignatureToIntegrityBlock(SignatureAttributesMap{Ed25519publicKeyAttributeName: []byte("publickey3")}, []byte("signature3"))
+
+	gotBytes, err := original.CborBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(gotBytes, originalBytes) {
+		t.Errorf("Modifying the clone should not modify the original.\ngot: %x\nwant: %x", gotBytes, originalBytes)
+	}
+}
+
+func TestIntegrityBlockSha512(t *testing.T) {
+	integrityBlock := generateEmptyIntegrityBlock()
+	integrityBlockBytes, err := integrityBlock.CborBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := integrityBlock.Sha512()
+	if err != nil {
+		t.Fatalf("Sha512. err: %v", err)
+	}
+	want := sha512.Sum512(integrityBlockBytes)
+	if !bytes.Equal(got, want[:]) {
+		t.Errorf("integrityblock: got: %x\nwant: %x", got, want)
+	}
+
+	integrityBlock.addNewSignatureToIntegrityBlock(SignatureAttributesMap{Ed25519publicKeyAttributeName: []byte("publickey")}, []byte("signature"))
+	gotWithSignature, err := integrityBlock.Sha512()
+	if err != nil {
+		t.Fatalf("Sha512. err: %v", err)
+	}
+	if bytes.Equal(gotWithSignature, got) {
+		t.Error("Adding a signature should change the digest of the integrity block.")
+	}
+
+	integrityBlock.SignatureStack = append(integrityBlock.SignatureStack, nil)
+	if _, err := integrityBlock.Sha512(); err == nil {
+		t.Error("Sha512 should fail when the integrity block cannot be encoded.")
+	}
+}
+
+func TestSortedAttributes(t *testing.T) {
+	integritySignature := &IntegritySignature{
+		SignatureAttributes: SignatureAttributesMap{
+			Ed25519publicKeyAttributeName: []byte("publickey"),
+			"b":                           []byte("2"),
+			"aa":                          []byte("3"),
+			"a":                           []byte("1"),
+		},
+		Signature: []byte("signature"),
+	}
+
+	var got []string
+	for _, attribute := range SortedAttributes(integritySignature) {
+		got = append(got, attribute.Key+"="+string(attribute.Value))
+	}
+	// Canonical CBOR orders the keys by their encoding, meaning shorter keys first.
+	want := "a=1,b=2,aa=3,ed25519PublicKey=publickey"
+	if strings.Join(got, ",") != want {
+		t.Errorf("integrityblock: got: %s\nwant: %s", strings.Join(got, ","), want)
+	}
+
+	if SortedAttributes(nil) != nil {
+		t.Error("SortedAttributes of a nil integrity signature should be nil.")
+	}
+}
+
+func TestIntegritySignatureEqualAndKey(t *testing.T) {
+	newSignature := func(attributes SignatureAttributesMap, signature string) *IntegritySignature {
+		return &IntegritySignature{SignatureAttributes: attributes, Signature: []byte(signature)}
+	}
+	a := newSignature(SignatureAttributesMap{Ed25519publicKeyAttributeName: []byte("publickey"), "a": []byte("1")}, "signature")
+
+	tests := []struct {
+		name  string
+		other *IntegritySignature
+		want  bool
+	}{
+		{"same", newSignature(SignatureAttributesMap{"a": []byte("1"), Ed25519publicKeyAttributeName: []byte("publickey")}, "signature"), true},
+		{"different signature", newSignature(SignatureAttributesMap{Ed25519publicKeyAttributeName: []byte("publickey"), "a": []byte("1")}, "othersignature"), false},
+		{"different attribute value", newSignature(SignatureAttributesMap{Ed25519publicKeyAttributeName: []byte("publickey"), "a": []byte("2")}, "signature"), false},
+		{"missing attribute", newSignature(SignatureAttributesMap{Ed25519publicKeyAttributeName: []byte("publickey")}, "signature"), false},
+		// The framing keeps the boundaries of the attributes and the signature apart.
+		{"shifted bytes", newSignature(SignatureAttributesMap{Ed25519publicKeyAttributeName: []byte("publickey"), "a": []byte("1s")}, "ignature"), false},
+	}
+	for _, test := range tests {
+		if got := a.Equal(test.other); got != test.want {
+			t.Errorf("%s: got Equal: %v, want: %v", test.name, got, test.want)
+		}
+		if got := a.Key() == test.other.Key(); got != test.want {
+			t.Errorf("%s: got equal keys: %v, want: %v", test.name, got, test.want)
+		}
+	}
+
+	var nilSignature *IntegritySignature
+	if !nilSignature.Equal(nil) || a.Equal(nil) || nilSignature.Equal(a) {
+		t.Error("integrityblock: nil integrity signatures should only equal each other")
+	}
+	if nilSignature.Key() != "" {
+		t.Error("Key of a nil integrity signature should be empty.")
+	}
+
+	seen := map[string]bool{}
+	for _, is := range []*IntegritySignature{a, tests[0].other, tests[1].other} {
+		seen[is.Key()] = true
+	}
+	if len(seen) != 2 {
+		t.Errorf("integrityblock: got %d distinct keys, want 2", len(seen))
+	}
+}
+
+func TestSignaturesByAlgorithm(t *testing.T) {
+	integrityBlock := generateEmptyIntegrityBlock()
+	integrityBlock.addNewSignatureToIntegrityBlock(SignatureAttributesMap{Ed25519publicKeyAttributeName: []byte("first")}, []byte("signature"))
+	integrityBlock.addNewSignatureToIntegrityBlock(SignatureAttributesMap{EcdsaP256SHA256PublicKeyAttributeName: []byte("second")}, []byte("signature"))
+	integrityBlock.addNewSignatureToIntegrityBlock(SignatureAttributesMap{Ed25519publicKeyAttributeName: []byte("third")}, []byte("signature"))
+	integrityBlock.addNewSignatureToIntegrityBlock(SignatureAttributesMap{
+		Ed25519publicKeyAttributeName:         []byte("both"),
+		EcdsaP256SHA256PublicKeyAttributeName: []byte("both"),
+	}, []byte("signature"))
+
+	tests := []struct {
+		attrName string
+		want     []string
+	}{
+		{attrName: Ed25519publicKeyAttributeName, want: []string{"third", "first"}},
+		{attrName: EcdsaP256SHA256PublicKeyAttributeName, want: []string{"second"}},
+		{attrName: "unknownPublicKey", want: nil},
+	}
+	for _, test := range tests {
+		var got []string
+		for _, integritySignature := range SignaturesByAlgorithm(integrityBlock, test.attrName) {
+			got = append(got, string(integritySignature.SignatureAttributes[test.attrName]))
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("integrityblock: %s: got: %v\nwant: %v", test.attrName, got, test.want)
+		}
+	}
+}
+
+func TestEachSignature(t *testing.T) {
+	integrityBlock := generateEmptyIntegrityBlock()
+	for _, signature := range []string{"signature3", "signature2", "signature1"} {
+		integrityBlock.addNewSignatureToIntegrityBlock(SignatureAttributesMap{Ed25519publicKeyAttributeName: []byte("publickey")}, []byte(signature))
+	}
+
+	var visited []string
+	err := EachSignature(integrityBlock, func(index int, is *IntegritySignature) (bool, error) {
+		if is != integrityBlock.SignatureStack[index] {
+			t.Errorf("integrityblock: signatureStack[%d] was not passed with its index", index)
+		}
+		visited = append(visited, string(is.Signature))
+		return false, nil
+	})
+	if err != nil {
+		t.Fatalf("EachSignature. err: %v", err)
+	}
+	if got, want := strings.Join(visited, ","), "signature1,signature2,signature3"; got != want {
+		t.Errorf("integrityblock: got: %s\nwant: %s", got, want)
+	}
+
+	numVisited := 0
+	err = EachSignature(integrityBlock, func(index int, is *IntegritySignature) (bool, error) {
+		numVisited++
+		return index == 1, nil
+	})
+	if err != nil || numVisited != 2 {
+		t.Errorf("integrityblock: got %d visited signatures and err: %v, want 2 and no error", numVisited, err)
+	}
+
+	errStop := errors.New("stop")
+	numVisited = 0
+	err = EachSignature(integrityBlock, func(index int, is *IntegritySignature) (bool, error) {
+		numVisited++
+		return false, errStop
+	})
+	if err != errStop || numVisited != 1 {
+		t.Errorf("integrityblock: got %d visited signatures and err: %v, want 1 and %v", numVisited, err, errStop)
+	}
+}
+
+// createTempFileHelper writes the given bytes into a temporary file and returns it opened for reading.
+func createTempFileHelper(t *testing.T, contents []byte) *os.File {
+	path := filepath.Join(t.TempDir(), "test.wbn")
+	if err := os.WriteFile(path, contents, 0644); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestReadIntegrityBlockBytes(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal("Failed to generate test keys")
+	}
+	bundle, err := os.ReadFile("./testfile.wbn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	signedBundle, err := SignBundleBytes(bundle, NewParsedEd25519KeySigningStrategy(priv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signedBundlePath := filepath.Join(t.TempDir(), "signed.wbn")
+	if err := os.WriteFile(signedBundlePath, signedBundle, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ReadIntegrityBlockBytes(signedBundlePath)
+	if err != nil {
+		t.Fatalf("ReadIntegrityBlockBytes. err: %v", err)
+	}
+	if want := signedBundle[:len(signedBundle)-len(bundle)]; !bytes.Equal(got, want) {
+		t.Errorf("integrityblock: got: %x\nwant: %x", got, want)
+	}
+
+	if _, err := ReadIntegrityBlockBytes("./testfile.wbn"); !errors.Is(err, ErrBundleNotSigned) {
+		t.Errorf("integrityblock: got err: %v\nwant: %v", err, ErrBundleNotSigned)
+	}
+}
+
+func TestPreparePayload(t *testing.T) {
+	bundleFile, err := os.Open("./testfile.wbn")
+	if err != nil {
+		t.Fatal("Failed to open the test file")
+	}
+	defer bundleFile.Close()
+
+	hash, payloadOffset, err := PreparePayload(bundleFile)
+	if err != nil {
+		t.Fatalf("PreparePayload. err: %v", err)
+	}
+	if payloadOffset != 0 {
+		t.Errorf("integrityblock: got payload offset: %d\nwant: 0", payloadOffset)
+	}
+	want, err := ComputeWebBundleSha512(bundleFile, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(hash, want) {
+		t.Errorf("integrityblock: got: %x\nwant: %x", hash, want)
+	}
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal("Failed to generate test keys")
+	}
+	signedBundlePath := filepath.Join(t.TempDir(), "signed.wbn")
+	if err := SignBundleFile("./testfile.wbn", signedBundlePath, NewParsedEd25519KeySigningStrategy(priv)); err != nil {
+		t.Fatal(err)
+	}
+	signedBundleFile, err := os.Open(signedBundlePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer signedBundleFile.Close()
+
+	signedHash, signedPayloadOffset, err := PreparePayload(signedBundleFile)
+	if err != nil {
+		t.Fatalf("PreparePayload. err: %v", err)
+	}
+	integrityBlockLen, err := IntegrityBlockLength(signedBundleFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signedPayloadOffset != integrityBlockLen || signedPayloadOffset == 0 {
+		t.Errorf("integrityblock: got payload offset: %d\nwant: %d", signedPayloadOffset, integrityBlockLen)
+	}
+	if !bytes.Equal(signedHash, want) {
+		t.Errorf("integrityblock: got: %x\nwant: %x", signedHash, want)
+	}
+
+	emptyFile := createTempFileHelper(t, []byte{})
+	defer emptyFile.Close()
+	if _, _, err := PreparePayload(emptyFile); err == nil {
+		t.Error("PreparePayload should fail with an empty file.")
+	}
+}
+
+func TestWriteSignedBundle(t *testing.T) {
+	integrityBlock := generateEmptyIntegrityBlock()
+	integrityBlock.addNewSignatureToIntegrityBlock(SignatureAttributesMap{Ed25519publicKeyAttributeName: []byte("publickey")}, bytes.Repeat([]byte{0x01}, ed25519.SignatureSize))
+	integrityBlockBytes, err := integrityBlock.CborBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	webBundleBytes := []byte("webbundle")
+
+	var buf bytes.Buffer
+	n, err := WriteSignedBundle(&buf, integrityBlock, bytes.NewReader(webBundleBytes))
+	if err != nil {
+		t.Fatalf("WriteSignedBundle. err: %v", err)
+	}
+
+	want := append(integrityBlockBytes, webBundleBytes...)
+	if n != int64(len(want)) {
+		t.Errorf("integrityblock: got %d bytes written, want %d", n, len(want))
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("integrityblock: got: %s\nwant: %s", hex.EncodeToString(buf.Bytes()), hex.EncodeToString(want))
+	}
+}
+
+func TestCanonicalAttributeOrder(t *testing.T) {
+	signatureAttributes := SignatureAttributesMap{"bb": {0x03}, "a": {0x01}, "ab": {0x02}}
+
+	if got, want := canonicalAttributeNames(signatureAttributes), []string{"a", "ab", "bb"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("integrityblock: got: %v\nwant: %v", got, want)
+	}
+
+	integrityBlock := generateEmptyIntegrityBlock()
+	integrityBlock.addNewSignatureToIntegrityBlock(signatureAttributes, []byte{})
+	got, err := integrityBlock.CborBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// {"a": h'01', "ab": h'02', "bb": h'03'}: the shorter key first, then the keys of the same length in bytewise order.
+	wantAttributes := []byte{0xa3, 0x61, 'a', 0x41, 0x01, 0x62, 'a', 'b', 0x41, 0x02, 0x62, 'b', 'b', 0x41, 0x03}
+	if !bytes.Contains(got, wantAttributes) {
+		t.Errorf("integrityblock: got: %x\nwant to contain: %x", got, wantAttributes)
+	}
+}
+
+func TestCborBytesWithLengthPrefix(t *testing.T) {
+	integrityBlock := generateEmptyIntegrityBlock()
+	integrityBlock.addNewSignatureToIntegrityBlock(SignatureAttributesMap{Ed25519publicKeyAttributeName: []byte("publickey")}, []byte("signature"))
+	integrityBlockBytes, err := integrityBlock.CborBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := integrityBlock.CborBytesWithLengthPrefix()
+	if err != nil {
+		t.Fatalf("CborBytesWithLengthPrefix. err: %v", err)
+	}
+	want := append([]byte{0, 0, 0, 0, 0, 0, 0, byte(len(integrityBlockBytes))}, integrityBlockBytes...)
+	if !bytes.Equal(got, want) {
+		t.Errorf("integrityblock: got: %x\nwant: %x", got, want)
+	}
+
+	integrityBlock.SignatureStack = append(integrityBlock.SignatureStack, nil)
+	if _, err := integrityBlock.CborBytesWithLengthPrefix(); err == nil {
+		t.Error("CborBytesWithLengthPrefix should fail when the integrity block cannot be encoded.")
+	}
+}
+
+func TestSerializedSize(t *testing.T) {
+	integrityBlock := generateEmptyIntegrityBlock()
+	for i := 0; i < 3; i++ {
+		integrityBlock.addNewSignatureToIntegrityBlock(SignatureAttributesMap{Ed25519publicKeyAttributeName: make([]byte, Ed25519PublicKeySize)}, make([]byte, Ed25519SignatureSize))
+
+		integrityBlockBytes, err := integrityBlock.CborBytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := integrityBlock.SerializedSize()
+		if err != nil {
+			t.Fatalf("SerializedSize. err: %v", err)
+		}
+		if got != len(integrityBlockBytes) {
+			t.Errorf("integrityblock: got: %d\nwant: %d", got, len(integrityBlockBytes))
+		}
+	}
+
+	integrityBlock.SignatureStack = append(integrityBlock.SignatureStack, nil)
+	if _, err := integrityBlock.SerializedSize(); err == nil {
+		t.Error("SerializedSize should fail when the integrity block cannot be encoded.")
+	}
+}
+
+func TestNewEmptyIntegrityBlock(t *testing.T) {
+	integrityBlock := NewEmptyIntegrityBlock()
+	got, err := integrityBlock.CborBytes()
+	if err != nil {
+		t.Fatalf("CborBytes. err: %v", err)
+	}
+	// [h'f09f968bf09f93a6', h'31620000', []]
+	want := []byte{0x83, 0x48, 0xf0, 0x9f, 0x96, 0x8b, 0xf0, 0x9f, 0x93, 0xa6, 0x44, 0x31, 0x62, 0x00, 0x00, 0x80}
+	if !bytes.Equal(got, want) {
+		t.Errorf("integrityblock: got: %x\nwant: %x", got, want)
+	}
+
+	integrityBlock.Magic[0] = 0
+	integrityBlock.Version[0] = 0
+	if IntegrityBlockMagic[0] == 0 || VersionB1[0] == 0 {
+		t.Error("Modifying the empty integrity block should not affect IntegrityBlockMagic or VersionB1.")
+	}
+}
+
+func TestIsEmptyAndSignatureCount(t *testing.T) {
+	integrityBlock := generateEmptyIntegrityBlock()
+	if !integrityBlock.IsEmpty() || integrityBlock.SignatureCount() != 0 {
+		t.Errorf("integrityblock: got IsEmpty %v and SignatureCount %d for an empty integrity block", integrityBlock.IsEmpty(), integrityBlock.SignatureCount())
+	}
+
+	for i := 1; i <= 2; i++ {
+		integrityBlock.addNewSignatureToIntegrityBlock(SignatureAttributesMap{Ed25519publicKeyAttributeName: []byte("publickey")}, []byte("signature"))
+		if integrityBlock.IsEmpty() {
+			t.Error("Integrity block with signatures should not be empty.")
+		}
+		if got := integrityBlock.SignatureCount(); got != i {
+			t.Errorf("integrityblock: got: %d\nwant: %d", got, i)
+		}
+	}
+}
+
+func TestVersionString(t *testing.T) {
+	tests := []struct {
+		version []byte
+		want    string
+	}{
+		{version: VersionB1, want: "b1"},
+		{version: VersionB2, want: "b2"},
+		{version: []byte{0x31, 0x00, 0x00, 0x00}, want: "unknown (31000000)"},
+	}
+
+	for _, test := range tests {
+		integrityBlock := generateEmptyIntegrityBlock()
+		integrityBlock.Version = test.version
+		if got := integrityBlock.VersionString(); got != test.want {
+			t.Errorf("integrityblock: got: %s\nwant: %s", got, test.want)
+		}
+		if got := IsSupportedVersion(test.version); got != strings.HasPrefix(test.want, "b") {
+			t.Errorf("integrityblock: IsSupportedVersion(%x) got: %v", test.version, got)
+		}
+	}
+}
+
+func TestCborBytesWithWrongSizedMagicAndVersion(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(ib *IntegrityBlock)
+	}{
+		{"empty magic", func(ib *IntegrityBlock) { ib.Magic = nil }},
+		{"too long magic", func(ib *IntegrityBlock) { ib.Magic = append(bytes.Clone(IntegrityBlockMagic), 0x00) }},
+		{"too short version", func(ib *IntegrityBlock) { ib.Version = []byte("1b") }},
+		{"too long version", func(ib *IntegrityBlock) { ib.Version = []byte("1b\x00\x00\x00") }},
+	}
+	for _, test := range tests {
+		integrityBlock := generateEmptyIntegrityBlock()
+		test.modify(integrityBlock)
+		if _, err := integrityBlock.CborBytes(); err == nil {
+			t.Errorf("%s: CborBytes should fail", test.name)
+		}
+		if err := integrityBlock.Validate(); err == nil {
+			t.Errorf("%s: Validate should fail", test.name)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	validSignature := &IntegritySignature{SignatureAttributes: SignatureAttributesMap{Ed25519publicKeyAttributeName: []byte("publickey")}, Signature: bytes.Repeat([]byte{0x01}, ed25519.SignatureSize)}
+
+	tests := []struct {
+		name      string
+		modify    func(ib *IntegrityBlock)
+		wantValid bool
+	}{
+		{
+			name:      "Empty integrity block",
+			modify:    func(ib *IntegrityBlock) {},
+			wantValid: true,
+		},
+		{
+			name:      "Integrity block with a signature",
+			modify:    func(ib *IntegrityBlock) { ib.SignatureStack = []*IntegritySignature{validSignature} },
+			wantValid: true,
+		},
+		{
+			name:   "Invalid magic",
+			modify: func(ib *IntegrityBlock) { ib.Magic = []byte("notmagic") },
+		},
+		{
+			name:   "Unsupported version",
+			modify: func(ib *IntegrityBlock) { ib.Version = []byte{0x39, 0x39, 0x00, 0x00} },
+		},
+		{
+			name:   "Too short magic",
+			modify: func(ib *IntegrityBlock) { ib.Magic = IntegrityBlockMagic[:7] },
+		},
+		{
+			name:   "Too long version",
+			modify: func(ib *IntegrityBlock) { ib.Version = append(bytes.Clone(VersionB1), 0x00) },
+		},
+		{
+			name:   "Nil signature",
+			modify: func(ib *IntegrityBlock) { ib.SignatureStack = []*IntegritySignature{validSignature, nil} },
+		},
+		{
+			name: "Empty signature",
+			modify: func(ib *IntegrityBlock) {
+				ib.SignatureStack = []*IntegritySignature{{SignatureAttributes: validSignature.SignatureAttributes}}
+			},
+		},
+		{
+			name: "Too short Ed25519 signature",
+			modify: func(ib *IntegrityBlock) {
+				ib.SignatureStack = []*IntegritySignature{{SignatureAttributes: validSignature.SignatureAttributes, Signature: []byte("signature")}}
+			},
+		},
+		{
+			name: "Too long ECDSA signature",
+			modify: func(ib *IntegrityBlock) {
+				ib.SignatureStack = []*IntegritySignature{{SignatureAttributes: SignatureAttributesMap{EcdsaP256SHA256PublicKeyAttributeName: []byte("publickey")}, Signature: make([]byte, 73)}}
+			},
+		},
+		{
+			name: "Missing public key",
+			modify: func(ib *IntegrityBlock) {
+				ib.SignatureStack = []*IntegritySignature{{SignatureAttributes: SignatureAttributesMap{}, Signature: []byte("signature")}}
+			},
+		},
+	}
+
+	for _, test := range tests {
+		integrityBlock := generateEmptyIntegrityBlock()
+		test.modify(integrityBlock)
+
+		err := integrityBlock.Validate()
+		if test.wantValid && err != nil {
+			t.Errorf("%s: Validate should succeed, got err: %v", test.name, err)
+		} else if !test.wantValid && err == nil {
+			t.Errorf("%s: Validate should fail.", test.name)
+		}
+
+		if _, err := integrityBlock.ValidatedCborBytes(); (err == nil) != test.wantValid {
+			t.Errorf("%s: ValidatedCborBytes got err: %v", test.name, err)
+		}
+	}
+}
+
+func TestGenerateSignedPayloadForExternalSigning(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal("Failed to generate test keys")
+	}
+	webBundleHash := sha512Helper([]byte("webbundle"))
+
+	integrityBlock := generateEmptyIntegrityBlock()
+	signatureAttributes := map[string][]byte{Ed25519publicKeyAttributeName: pub}
+
+	payload, err := GenerateSignedPayload(integrityBlock, signatureAttributes, webBundleHash)
+	if err != nil {
+		t.Fatalf("GenerateSignedPayload. err: %v", err)
+	}
+
+	integrityBlockBytes, err := integrityBlock.CborBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := GenerateDataToBeSigned(webBundleHash, integrityBlockBytes, signatureAttributes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(payload, want) {
+		t.Errorf("integrityblock: got: %s\nwant: %s", hex.EncodeToString(payload), hex.EncodeToString(want))
+	}
+
+	// Signing the payload outside of the package produces a valid signature.
+	AppendSignature(integrityBlock, &IntegritySignature{SignatureAttributes: signatureAttributes, Signature: ed25519.Sign(priv, payload)})
+	result, err := VerifyIntegrityBlock(integrityBlock, webBundleHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid := result.ValidSignatures()
+	if len(valid) != 1 {
+		t.Error("Externally signed payload should produce a valid signature.")
+	}
+}
+
+// failingSignerHelper is a Signer whose signing always fails, like a KMS which is not reachable.
+type failingSignerHelper struct {
+	publicKey ed25519.PublicKey
+}
+
+func (fs failingSignerHelper) Sign(payload []byte) ([]byte, error) {
+	return nil, errors.New("KMS not reachable")
+}
+
+func (fs failingSignerHelper) PublicKey() ed25519.PublicKey {
+	return fs.publicKey
+}
+
+func TestSignIntegrityBlockWithFailingSigner(t *testing.T) {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal("Failed to generate test keys")
+	}
+
+	if _, err := SignIntegrityBlock(generateEmptyIntegrityBlock(), failingSignerHelper{pub}, sha512Helper([]byte("webbundle"))); err == nil {
+		t.Error("SignIntegrityBlock should fail when the signer fails.")
+	}
+}
+
+func bytesToCborAndToReadableStringHelper(bts []byte) (string, error) {
+	var buf bytes.Buffer
+	enc := cbor.NewEncoder(&buf)
+
+	err := enc.EncodeByteString(bts)
+	if err != nil {
+		return "", err
+	}
+
+	cborAsString, err := testhelper.CborBinaryToReadableString(buf.Bytes())
+	if err != nil {
+		return "", err
+	}
+	return cborAsString, nil
+}
+
+// deadlineSignerHelper is a ContextSigner which, like a KMS not answering, only returns once the context is done.
+type deadlineSignerHelper struct {
+	publicKey ed25519.PublicKey
+}
+
+func (ds deadlineSignerHelper) Sign(ctx context.Context, payload []byte) ([]byte, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (ds deadlineSignerHelper) PublicKey() ed25519.PublicKey {
+	return ds.publicKey
+}
+
+func TestSignIntegrityBlockContext(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal("Failed to generate test keys")
+	}
+	signer := NewParsedEd25519KeySigningStrategy(priv)
+	webBundleHash := sha512Helper([]byte("webbundle"))
+
+	got, err := SignIntegrityBlockContext(context.Background(), generateEmptyIntegrityBlock(), NewContextSigner(signer), webBundleHash)
+	if err != nil {
+		t.Fatalf("SignIntegrityBlockContext. err: %v", err)
+	}
+	want, err := SignIntegrityBlock(generateEmptyIntegrityBlock(), signer, webBundleHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Equal(want) {
+		t.Error("SignIntegrityBlockContext should create the same signature as SignIntegrityBlock.")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := SignIntegrityBlockContext(ctx, generateEmptyIntegrityBlock(), deadlineSignerHelper{signer.PublicKey()}, webBundleHash); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("integrityblock: got err: %v\nwant: %v", err, context.DeadlineExceeded)
+	}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := SignIntegrityBlockContext(cancelled, generateEmptyIntegrityBlock(), NewContextSigner(signer), webBundleHash); !errors.Is(err, context.Canceled) {
+		t.Errorf("integrityblock: got err: %v\nwant: %v", err, context.Canceled)
 	}
-	return cborAsString, nil
 }
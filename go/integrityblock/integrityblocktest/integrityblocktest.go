@@ -0,0 +1,123 @@
+// Package integrityblocktest provides utilities for testing code working with integrity blocks.
+package integrityblocktest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/WICG/webpackage/go/integrityblock"
+)
+
+// AssertBlockEqual deep-compares the magic, version, the signature stack, including the signature
+// attributes and the signatures, and the unknown fields of the given integrity blocks. It returns nil if they are equal and
+// otherwise an error listing every difference found.
+func AssertBlockEqual(a, b *integrityblock.IntegrityBlock) error {
+	if a == nil || b == nil {
+		if a == b {
+			return nil
+		}
+		return fmt.Errorf("integrityblocktest: got %v, want %v", a, b)
+	}
+
+	var diffs []string
+	if !bytes.Equal(a.Magic, b.Magic) {
+		diffs = append(diffs, fmt.Sprintf("magic: %x != %x", a.Magic, b.Magic))
+	}
+	if !bytes.Equal(a.Version, b.Version) {
+		diffs = append(diffs, fmt.Sprintf("version: %x != %x", a.Version, b.Version))
+	}
+	if len(a.SignatureStack) != len(b.SignatureStack) {
+		diffs = append(diffs, fmt.Sprintf("signatureStack: %d signatures != %d signatures", len(a.SignatureStack), len(b.SignatureStack)))
+	} else {
+		for i := range a.SignatureStack {
+			diffs = append(diffs, signatureDiffs(fmt.Sprintf("signatureStack[%d]", i), a.SignatureStack[i], b.SignatureStack[i])...)
+		}
+	}
+	if len(a.UnknownFields) != len(b.UnknownFields) {
+		diffs = append(diffs, fmt.Sprintf("unknownFields: %d fields != %d fields", len(a.UnknownFields), len(b.UnknownFields)))
+	} else {
+		for i := range a.UnknownFields {
+			if !bytes.Equal(a.UnknownFields[i], b.UnknownFields[i]) {
+				diffs = append(diffs, fmt.Sprintf("unknownFields[%d]: %x != %x", i, a.UnknownFields[i], b.UnknownFields[i]))
+			}
+		}
+	}
+
+	if len(diffs) > 0 {
+		return fmt.Errorf("integrityblocktest: integrity blocks differ:\n%s", strings.Join(diffs, "\n"))
+	}
+	return nil
+}
+
+// signatureDiffs returns the differences between two integrity signatures, each prefixed with the given path.
+func signatureDiffs(path string, a, b *integrityblock.IntegritySignature) []string {
+	if a == nil || b == nil {
+		if a == b {
+			return nil
+		}
+		return []string{fmt.Sprintf("%s: %v != %v", path, a, b)}
+	}
+
+	var diffs []string
+	keys := map[string]struct{}{}
+	for key := range a.SignatureAttributes {
+		keys[key] = struct{}{}
+	}
+	for key := range b.SignatureAttributes {
+		keys[key] = struct{}{}
+	}
+	sortedKeys := make([]string, 0, len(keys))
+	for key := range keys {
+		sortedKeys = append(sortedKeys, key)
+	}
+	sort.Strings(sortedKeys)
+
+	for _, key := range sortedKeys {
+		valueA, okA := a.SignatureAttributes[key]
+		valueB, okB := b.SignatureAttributes[key]
+		switch {
+		case !okA:
+			diffs = append(diffs, fmt.Sprintf("%s.signatureAttributes[%q]: missing != %x", path, key, valueB))
+		case !okB:
+			diffs = append(diffs, fmt.Sprintf("%s.signatureAttributes[%q]: %x != missing", path, key, valueA))
+		case !bytes.Equal(valueA, valueB):
+			diffs = append(diffs, fmt.Sprintf("%s.signatureAttributes[%q]: %x != %x", path, key, valueA, valueB))
+		}
+	}
+	if !bytes.Equal(a.Signature, b.Signature) {
+		diffs = append(diffs, fmt.Sprintf("%s.signature: %x != %x", path, a.Signature, b.Signature))
+	}
+	return diffs
+}
+
+// signedPayloadElements are the names of the length-prefixed elements of the signed payload in their order.
+var signedPayloadElements = []string{"webBundleHash", "integrityBlock", "signatureAttributes"}
+
+// DumpSignedPayload returns a human readable dump of the signed payload created e.g. with
+// `integrityblock.GenerateSignedPayload`, listing the offset, length and the hex encoded bytes of every element,
+// so that it can be compared against the bytes in the spec. It fails if the payload is not framed as expected.
+func DumpSignedPayload(payload []byte) (string, error) {
+	var dump strings.Builder
+	offset := 0
+	for _, name := range signedPayloadElements {
+		if len(payload)-offset < 8 {
+			return "", fmt.Errorf("integrityblocktest: Signed payload is truncated at the length of %s at offset %d.", name, offset)
+		}
+		length := binary.BigEndian.Uint64(payload[offset : offset+8])
+		fmt.Fprintf(&dump, "%06d  length of %s: %x (%d)\n", offset, name, payload[offset:offset+8], length)
+		offset += 8
+
+		if uint64(len(payload)-offset) < length {
+			return "", fmt.Errorf("integrityblocktest: Signed payload is truncated at %s at offset %d.", name, offset)
+		}
+		fmt.Fprintf(&dump, "%06d  %s: %x\n", offset, name, payload[offset:offset+int(length)])
+		offset += int(length)
+	}
+	if offset != len(payload) {
+		return "", fmt.Errorf("integrityblocktest: Signed payload has %d unexpected bytes at offset %d.", len(payload)-offset, offset)
+	}
+	return dump.String(), nil
+}
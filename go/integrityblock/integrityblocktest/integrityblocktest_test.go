@@ -0,0 +1,96 @@
+package integrityblocktest
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/WICG/webpackage/go/integrityblock"
+)
+
+func generateIntegrityBlockHelper(t *testing.T) *integrityblock.IntegrityBlock {
+	integrityBlock := &integrityblock.IntegrityBlock{
+		Magic:   integrityblock.IntegrityBlockMagic,
+		Version: integrityblock.VersionB1,
+	}
+	err := integrityblock.AppendSignature(integrityBlock, &integrityblock.IntegritySignature{
+		SignatureAttributes: integrityblock.SignatureAttributesMap{integrityblock.Ed25519publicKeyAttributeName: []byte("publickey")},
+		Signature:           []byte("signature"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return integrityBlock
+}
+
+func TestAssertBlockEqualWithParsedIntegrityBlock(t *testing.T) {
+	integrityBlock := generateIntegrityBlockHelper(t)
+	integrityBlockBytes, err := integrityBlock.CborBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := integrityblock.ParseIntegrityBlock(bytes.NewReader(integrityBlockBytes))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := AssertBlockEqual(parsed, integrityBlock); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAssertBlockEqualReportsDifferences(t *testing.T) {
+	a := generateIntegrityBlockHelper(t)
+	b := generateIntegrityBlockHelper(t)
+	b.Version = integrityblock.VersionB2
+	b.SignatureStack[0].SignatureAttributes = integrityblock.SignatureAttributesMap{"hello": []byte("world")}
+	b.SignatureStack[0].Signature = []byte("othersignature")
+	b.UnknownFields = [][]byte{{0x01}}
+
+	err := AssertBlockEqual(a, b)
+	if err == nil {
+		t.Fatal("AssertBlockEqual should fail with different integrity blocks.")
+	}
+
+	for _, want := range []string{"version", `signatureAttributes["ed25519PublicKey"]`, `signatureAttributes["hello"]`, "signatureStack[0].signature", "unknownFields"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("integrityblocktest: error should mention %s, got: %v", want, err)
+		}
+	}
+}
+
+func TestDumpSignedPayload(t *testing.T) {
+	integrityBlock := &integrityblock.IntegrityBlock{
+		Magic:   integrityblock.IntegrityBlockMagic,
+		Version: integrityblock.VersionB1,
+	}
+	signatureAttributes := integrityblock.SignatureAttributesMap{"k": []byte("v")}
+	payload, err := integrityblock.GenerateSignedPayload(integrityBlock, signatureAttributes, []byte{0xaa, 0xbb})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := DumpSignedPayload(payload)
+	if err != nil {
+		t.Fatalf("DumpSignedPayload. err: %v", err)
+	}
+	want := strings.Join([]string{
+		"000000  length of webBundleHash: 0000000000000002 (2)",
+		"000008  webBundleHash: aabb",
+		"000010  length of integrityBlock: 0000000000000010 (16)",
+		"000018  integrityBlock: 8348f09f968bf09f93a6443162000080",
+		"000034  length of signatureAttributes: 0000000000000005 (5)",
+		"000042  signatureAttributes: a1616b4176",
+		"",
+	}, "\n")
+	if got != want {
+		t.Errorf("integrityblocktest: got:\n%s\nwant:\n%s", got, want)
+	}
+
+	for _, invalidPayload := range [][]byte{payload[:4], payload[:len(payload)-1], append(payload, 0x00)} {
+		if _, err := DumpSignedPayload(invalidPayload); err == nil {
+			t.Errorf("DumpSignedPayload should fail with a payload of %d bytes.", len(invalidPayload))
+		}
+	}
+}
@@ -4,7 +4,7 @@ import (
 	"crypto/ed25519"
 )
 
-// ParsedEd25519KeySigningStrategy implementing `ISigningStrategy` is the simplest way to
+// ParsedEd25519KeySigningStrategy implementing `ISigningStrategy` and `Signer` is the simplest way to
 // sign a web bundle just by passing a parsed private key.
 type ParsedEd25519KeySigningStrategy struct {
 	ed25519privKey ed25519.PrivateKey
@@ -23,3 +23,7 @@ func (bss ParsedEd25519KeySigningStrategy) Sign(data []byte) ([]byte, error) {
 func (bss ParsedEd25519KeySigningStrategy) GetPublicKey() (ed25519.PublicKey, error) {
 	return bss.ed25519privKey.Public().(ed25519.PublicKey), nil
 }
+
+func (bss ParsedEd25519KeySigningStrategy) PublicKey() ed25519.PublicKey {
+	return bss.ed25519privKey.Public().(ed25519.PublicKey)
+}
@@ -0,0 +1,96 @@
+package integrityblock
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/WICG/webpackage/go/integrityblock/webbundleid"
+)
+
+// RunSign signs the unsigned web bundle at `input` with the Ed25519 private key read from the PEM file at
+// `keyPath` and writes the signed web bundle into `output`. Human-readable progress is written into `w`, so
+// a command-line tool only needs to parse its arguments and call this. The lower-level functions like
+// `SignBundleFile` remain available for finer control.
+func RunSign(input, output, keyPath string, w io.Writer) error {
+	pemBytes, err := os.ReadFile(keyPath)
+	if err != nil {
+		return fmt.Errorf("integrityblock: Unable to read the private key: %v", err)
+	}
+	privateKey, err := ParseEd25519PrivateKeyFromPEM(pemBytes)
+	if err != nil {
+		return err
+	}
+	signer := NewParsedEd25519KeySigningStrategy(privateKey)
+
+	webBundleId, err := webbundleid.NewWebBundleId(signer.PublicKey())
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Signing %s\n", input)
+	fmt.Fprintf(w, "Web Bundle ID: %s\n", webBundleId)
+
+	if err := SignBundleFile(input, output, signer); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Wrote the signed web bundle to %s\n", output)
+	return nil
+}
+
+// RunVerify verifies the signed web bundle at `bundlePath` and writes a human-readable report of every signature
+// into `w`. If `expectedKeys` is not empty, at least one valid signature must be from one of the listed base64
+// encoded Ed25519 public keys. The returned error tells the outcome apart: `ErrBundleNotSigned` if the web bundle
+// has no integrity block, `ErrInvalidSignature` or `ErrSignerNotAllowed` if it is signed but doesn't verify, and
+// nil if it is valid.
+func RunVerify(bundlePath string, expectedKeys []string, w io.Writer) error {
+	allowed := make([]ed25519.PublicKey, 0, len(expectedKeys))
+	for _, expectedKey := range expectedKeys {
+		publicKey, err := decodeBase64PublicKey(expectedKey)
+		if err != nil {
+			return err
+		}
+		allowed = append(allowed, publicKey)
+	}
+
+	result, err := VerifyBundleFile(bundlePath, allowed)
+	switch {
+	case errors.Is(err, ErrBundleNotSigned):
+		fmt.Fprintf(w, "%s is not signed\n", bundlePath)
+		return err
+	case errors.Is(err, ErrNoSignatures):
+		fmt.Fprintf(w, "%s is signed but invalid: %v\n", bundlePath, err)
+		return err
+	case result == nil:
+		return err
+	}
+
+	numInvalid := 0
+	for _, signatureResult := range result.Signatures {
+		fmt.Fprintf(w, "signatureStack[%d]: ", signatureResult.Index)
+		if !signatureResult.Valid {
+			numInvalid++
+			fmt.Fprintf(w, "invalid: %v\n", signatureResult.Err)
+			continue
+		}
+
+		publicKey, err := ed25519PublicKeyFromAttributes(signatureResult.Signature.SignatureAttributes)
+		if err != nil {
+			fmt.Fprintf(w, "valid, public key: %x\n", signatureResult.PublicKey)
+			continue
+		}
+		fmt.Fprintf(w, "valid, Web Bundle ID: %s\n", webbundleid.GetWebBundleId(publicKey))
+	}
+
+	if numInvalid > 0 {
+		fmt.Fprintf(w, "%s is signed but invalid\n", bundlePath)
+		return fmt.Errorf("%w %d of %d signatures failed the verification.", ErrInvalidSignature, numInvalid, len(result.Signatures))
+	}
+	if errors.Is(err, ErrSignerNotAllowed) {
+		fmt.Fprintf(w, "%s is signed but not by any of the expected keys\n", bundlePath)
+		return err
+	}
+	fmt.Fprintf(w, "%s is valid\n", bundlePath)
+	return nil
+}
@@ -0,0 +1,127 @@
+package integrityblock
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunSign(t *testing.T) {
+	_, _, pemPrivate, webBundleId, err := GenerateEd25519KeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, "privatekey.pem")
+	if err := os.WriteFile(keyPath, pemPrivate, 0600); err != nil {
+		t.Fatal(err)
+	}
+	outputPath := filepath.Join(dir, "signed.wbn")
+
+	var out bytes.Buffer
+	if err := RunSign("./testfile.wbn", outputPath, keyPath, &out); err != nil {
+		t.Fatalf("RunSign. err: %v", err)
+	}
+	if !strings.Contains(out.String(), "Web Bundle ID: "+webBundleId.String()) {
+		t.Errorf("integrityblock: output doesn't contain the Web Bundle ID: %s", out.String())
+	}
+
+	signedBundleFile, err := os.Open(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer signedBundleFile.Close()
+	hasIntegrityBlock, err := WebBundleHasIntegrityBlock(signedBundleFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasIntegrityBlock {
+		t.Error("Signed web bundle should have an integrity block.")
+	}
+}
+
+func TestRunSignWithMissingKey(t *testing.T) {
+	dir := t.TempDir()
+	outputPath := filepath.Join(dir, "signed.wbn")
+
+	var out bytes.Buffer
+	if err := RunSign("./testfile.wbn", outputPath, filepath.Join(dir, "missing.pem"), &out); err == nil {
+		t.Error("RunSign should fail when the private key cannot be read.")
+	}
+	if _, err := os.Stat(outputPath); !os.IsNotExist(err) {
+		t.Error("RunSign should not create the output file when the private key cannot be read.")
+	}
+}
+
+func TestRunVerify(t *testing.T) {
+	pub, priv, _, webBundleId, err := GenerateEd25519KeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signedBundlePath := filepath.Join(t.TempDir(), "signed.wbn")
+	if err := SignBundleFile("./testfile.wbn", signedBundlePath, NewParsedEd25519KeySigningStrategy(priv)); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := RunVerify(signedBundlePath, nil, &out); err != nil {
+		t.Fatalf("RunVerify. err: %v\n%s", err, out.String())
+	}
+	if !strings.Contains(out.String(), "Web Bundle ID: "+webBundleId.String()) {
+		t.Errorf("integrityblock: report doesn't contain the Web Bundle ID: %s", out.String())
+	}
+
+	out.Reset()
+	if err := RunVerify(signedBundlePath, []string{base64.StdEncoding.EncodeToString(pub)}, &out); err != nil {
+		t.Errorf("RunVerify with the expected key. err: %v\n%s", err, out.String())
+	}
+
+	otherPub, _, _, _, err := GenerateEd25519KeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	if err := RunVerify(signedBundlePath, []string{base64.StdEncoding.EncodeToString(otherPub)}, &out); !errors.Is(err, ErrSignerNotAllowed) {
+		t.Errorf("integrityblock: got err: %v\nwant: %v", err, ErrSignerNotAllowed)
+	}
+}
+
+func TestRunVerifyWithUnsignedBundle(t *testing.T) {
+	var out bytes.Buffer
+	if err := RunVerify("./testfile.wbn", nil, &out); !errors.Is(err, ErrBundleNotSigned) {
+		t.Errorf("integrityblock: got err: %v\nwant: %v", err, ErrBundleNotSigned)
+	}
+}
+
+func TestRunVerifyWithInvalidSignature(t *testing.T) {
+	_, priv, _, _, err := GenerateEd25519KeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signedBundle, err := os.ReadFile("./testfile.wbn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	signedBundle, err = SignBundleBytes(signedBundle, NewParsedEd25519KeySigningStrategy(priv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Flip a bit in the web bundle bytes so that the web bundle hash no longer matches the signature.
+	signedBundle[len(signedBundle)-10] ^= 0x01
+	signedBundlePath := filepath.Join(t.TempDir(), "tampered.wbn")
+	if err := os.WriteFile(signedBundlePath, signedBundle, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := RunVerify(signedBundlePath, nil, &out); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("integrityblock: got err: %v\nwant: %v", err, ErrInvalidSignature)
+	}
+	if !strings.Contains(out.String(), "signed but invalid") {
+		t.Errorf("integrityblock: report should tell the web bundle is invalid: %s", out.String())
+	}
+}
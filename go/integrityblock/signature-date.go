@@ -0,0 +1,34 @@
+package integrityblock
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// DateAttributeName is the name of the signature attribute holding the time of signing. Like all signature
+// attributes it is part of the signed payload, so it cannot be changed without invalidating the signature.
+const DateAttributeName = "date"
+
+// SetDate sets the date attribute to the given time, encoded as an RFC 3339 timestamp in UTC with second
+// precision.
+func (sa SignatureAttributesMap) SetDate(t time.Time) {
+	sa[DateAttributeName] = []byte(t.UTC().Format(time.RFC3339))
+}
+
+// Date returns the time of signing from the date attribute and whether the attribute was present. Both RFC 3339
+// timestamps and decimal seconds since the Unix epoch are accepted.
+func (is *IntegritySignature) Date() (time.Time, bool, error) {
+	value, ok := is.Attribute(DateAttributeName)
+	if !ok {
+		return time.Time{}, false, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, string(value)); err == nil {
+		return t, true, nil
+	}
+	if seconds, err := strconv.ParseInt(string(value), 10, 64); err == nil {
+		return time.Unix(seconds, 0).UTC(), true, nil
+	}
+	return time.Time{}, true, fmt.Errorf("integrityblock: The %s attribute %q is neither an RFC 3339 timestamp nor seconds since the Unix epoch.", DateAttributeName, value)
+}
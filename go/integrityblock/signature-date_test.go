@@ -0,0 +1,91 @@
+package integrityblock
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+	"time"
+)
+
+func TestSignIntegrityBlockWithDate(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal("Failed to generate test keys")
+	}
+	webBundleHash := sha512Helper([]byte("webbundle"))
+	date := time.Date(2023, time.March, 1, 12, 30, 0, 0, time.FixedZone("UTC+2", 2*60*60))
+
+	additionalAttributes := SignatureAttributesMap{}
+	additionalAttributes.SetDate(date)
+	integrityBlock := generateEmptyIntegrityBlock()
+	integritySignature, err := SignIntegrityBlockWithAttributes(integrityBlock, NewParsedEd25519KeySigningStrategy(priv), webBundleHash, additionalAttributes)
+	if err != nil {
+		t.Fatalf("SignIntegrityBlockWithAttributes. err: %v", err)
+	}
+	if got := string(integritySignature.SignatureAttributes[DateAttributeName]); got != "2023-03-01T10:30:00Z" {
+		t.Errorf("integrityblock: got: %s\nwant: 2023-03-01T10:30:00Z", got)
+	}
+
+	got, ok, err := integritySignature.Date()
+	if err != nil || !ok {
+		t.Fatalf("Date. ok: %v, err: %v", ok, err)
+	}
+	if !got.Equal(date) {
+		t.Errorf("integrityblock: got: %v\nwant: %v", got, date)
+	}
+
+	// The date is part of the signed payload, so changing it invalidates the signature.
+	if err := AppendSignature(integrityBlock, integritySignature); err != nil {
+		t.Fatal(err)
+	}
+	integritySignature.SignatureAttributes.SetDate(date.Add(time.Hour))
+	result, err := VerifyIntegrityBlock(integrityBlock, webBundleHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Valid {
+		t.Error("Signature with a modified date should not be valid.")
+	}
+}
+
+func TestSignIntegrityBlockWithAttributesRejectsPublicKeys(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal("Failed to generate test keys")
+	}
+	for _, name := range PublicKeyAttributeNames() {
+		additionalAttributes := SignatureAttributesMap{name: []byte("publickey")}
+		if _, err := SignIntegrityBlockWithAttributes(generateEmptyIntegrityBlock(), NewParsedEd25519KeySigningStrategy(priv), sha512Helper(nil), additionalAttributes); err == nil {
+			t.Errorf("SignIntegrityBlockWithAttributes should fail with an additional %s attribute.", name)
+		}
+	}
+}
+
+func TestIntegritySignatureDate(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{value: "2023-03-01T10:30:00Z", want: time.Date(2023, time.March, 1, 10, 30, 0, 0, time.UTC)},
+		{value: "1677666600", want: time.Date(2023, time.March, 1, 10, 30, 0, 0, time.UTC)},
+		{value: "yesterday", wantErr: true},
+	}
+	for _, test := range tests {
+		integritySignature := &IntegritySignature{SignatureAttributes: SignatureAttributesMap{DateAttributeName: []byte(test.value)}}
+		got, ok, err := integritySignature.Date()
+		if !ok {
+			t.Errorf("%q: date attribute should be present", test.value)
+		}
+		if (err != nil) != test.wantErr {
+			t.Errorf("%q: got err: %v, want error: %v", test.value, err, test.wantErr)
+		}
+		if !got.Equal(test.want) {
+			t.Errorf("%q: got: %v\nwant: %v", test.value, got, test.want)
+		}
+	}
+
+	if _, ok, err := (&IntegritySignature{SignatureAttributes: SignatureAttributesMap{}}).Date(); ok || err != nil {
+		t.Errorf("integrityblock: got ok: %v, err: %v for a missing date attribute", ok, err)
+	}
+}
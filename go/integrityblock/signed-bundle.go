@@ -0,0 +1,366 @@
+package integrityblock
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/WICG/webpackage/go/integrityblock/webbundleid"
+)
+
+// SigningReport describes the outcome of signing a web bundle without writing the signed web bundle.
+type SigningReport struct {
+	// IntegrityBlock contains the CBOR encoded integrity block which would be prepended to the web bundle.
+	IntegrityBlock []byte
+	// WebBundleId is the Web Bundle ID derived from the public key of the signer.
+	WebBundleId webbundleid.WebBundleId
+	// SignedBundleSize is the total size of the signed web bundle in bytes.
+	SignedBundleSize int64
+}
+
+// SignBundleFile reads the unsigned web bundle from `inputPath`, signs it with the given signer and writes
+// the signed web bundle into `outputPath`. It fails with `ErrBundleAlreadySigned` if the web bundle already
+// contains an integrity block. If signing or writing fails, the partially written output file is removed.
+func SignBundleFile(inputPath, outputPath string, signer Signer) error {
+	if inputPath == outputPath {
+		return errors.New("integrityblock: Input and output file cannot be the same.")
+	}
+
+	bundleFile, err := os.Open(inputPath)
+	if err != nil {
+		return err
+	}
+	defer bundleFile.Close()
+
+	fileStats, err := bundleFile.Stat()
+	if err != nil {
+		return err
+	}
+
+	// The output file is truncated when it is created, so it must not be the input file under another name,
+	// e.g. through a symlink or a relative path.
+	if outputStats, err := os.Stat(outputPath); err == nil && os.SameFile(fileStats, outputStats) {
+		return errors.New("integrityblock: Input and output file cannot be the same.")
+	}
+
+	// Fail before creating the output file if the web bundle cannot be signed.
+	if _, _, err := ObtainIntegrityBlockFrom(bundleFile, fileStats.Size(), nil); err != nil {
+		return err
+	}
+
+	signedBundleFile, err := os.Create(outputPath)
+	if err != nil {
+		return err
+	}
+
+	err = signAndWriteBundle(bundleFile, fileStats.Size(), signedBundleFile, signer)
+	if closeErr := signedBundleFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(outputPath)
+		return err
+	}
+	return nil
+}
+
+// VerifyBundleFile verifies the signed web bundle at `path`, which is the verification counterpart of
+// `SignBundleFile`. It returns the result of every signature like `VerifyIntegrityBlock`; without an allowlist,
+// invalid signatures are reported in the result rather than as an error. If `allowed` is not empty, the result
+// is checked against it with the same policy as in `VerifyAgainstAllowlist`, and the error is returned along
+// with the result.
+func VerifyBundleFile(path string, allowed []ed25519.PublicKey) (*VerificationResult, error) {
+	bundleFile, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("integrityblock: Failed to open the web bundle: %w", err)
+	}
+	defer bundleFile.Close()
+
+	integrityBlock, offset, err := parseExistingIntegrityBlock(bundleFile)
+	if err != nil {
+		return nil, fmt.Errorf("integrityblock: Failed to read the integrity block of %s: %w", path, err)
+	}
+
+	webBundleHash, err := ComputeWebBundleSha512(bundleFile, offset)
+	if err != nil {
+		return nil, fmt.Errorf("integrityblock: Failed to hash the web bundle %s: %w", path, err)
+	}
+
+	result, err := VerifyIntegrityBlock(integrityBlock, webBundleHash)
+	if err != nil {
+		return nil, fmt.Errorf("integrityblock: Failed to verify %s: %w", path, err)
+	}
+
+	if len(allowed) == 0 {
+		return result, nil
+	}
+	return result, checkAllowlist(result, allowed)
+}
+
+// VerifySignedBundleFile verifies the signed web bundle at `path` like `VerifyBundleFile` without an allowlist
+// and additionally returns the Ed25519 public keys of the valid signatures in the signature stack's order, which
+// tell by whom the web bundle is signed. It fails with `ErrBundleNotSigned` if the web bundle is not signed.
+func VerifySignedBundleFile(path string) (*VerificationResult, []ed25519.PublicKey, error) {
+	result, err := VerifyBundleFile(path, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var validSigners []ed25519.PublicKey
+	for _, integritySignature := range result.ValidSignatures() {
+		publicKey, err := ed25519PublicKeyFromAttributes(integritySignature.SignatureAttributes)
+		if err != nil {
+			// Signers using other algorithms don't have an Ed25519 public key.
+			continue
+		}
+		validSigners = append(validSigners, publicKey)
+	}
+	return result, validSigners, nil
+}
+
+// SignBundleFileInPlace signs the unsigned web bundle at `path` with the given signer and replaces it with
+// the signed web bundle atomically: the signed web bundle is written into a temporary file in the same
+// directory, which is synced to disk and renamed over the original only if everything succeeded, and the
+// directory is synced after the rename so that the replacement survives a crash. It fails with
+// `ErrBundleAlreadySigned` if the web bundle already contains an integrity block.
+func SignBundleFileInPlace(path string, signer Signer) error {
+	bundleFile, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer bundleFile.Close()
+
+	fileStats, err := bundleFile.Stat()
+	if err != nil {
+		return err
+	}
+
+	// Fail before creating the temporary file if the web bundle cannot be signed.
+	if _, _, err := ObtainIntegrityBlockFrom(bundleFile, fileStats.Size(), nil); err != nil {
+		return err
+	}
+
+	tempFile, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tempPath := tempFile.Name()
+
+	err = signAndWriteBundle(bundleFile, fileStats.Size(), tempFile, signer)
+	if err == nil {
+		err = tempFile.Chmod(fileStats.Mode().Perm())
+	}
+	if err == nil {
+		err = tempFile.Sync()
+	}
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	// Windows cannot rename over a file which is still open.
+	if closeErr := bundleFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tempPath, path)
+	}
+	if err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+// syncDir syncs the directory at `path` to disk, making a rename inside it durable. It does nothing on Windows,
+// which doesn't support syncing directories.
+func syncDir(path string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	dir, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	err = dir.Sync()
+	if closeErr := dir.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// SignBundleBytes signs the unsigned web bundle held in memory with the given signer and returns the signed
+// web bundle, meaning the integrity block followed by the web bundle bytes. It fails with
+// `ErrBundleAlreadySigned` if the web bundle already contains an integrity block.
+func SignBundleBytes(bundle []byte, signer Signer) ([]byte, error) {
+	return SignWithKeyring(bundle, []Signer{signer})
+}
+
+// SignWithKeyring signs the unsigned web bundle held in memory with each of the given signers in order and
+// returns the signed web bundle. Every signer signs over the integrity block containing the signatures of the
+// signers before it, so the signature of the first signer ends up last on the signature stack and the signature
+// of the last signer first. It fails with `ErrBundleAlreadySigned` if the web bundle already contains an
+// integrity block.
+func SignWithKeyring(bundle []byte, signers []Signer) ([]byte, error) {
+	if len(signers) == 0 {
+		return nil, errors.New("integrityblock: At least one signer is required.")
+	}
+
+	integrityBlockLen, err := integrityBlockLengthFromSeeker(bytes.NewReader(bundle))
+	if err != nil {
+		return nil, err
+	}
+	if integrityBlockLen != 0 {
+		return nil, fmt.Errorf("%w Please provide an unsigned web bundle.", ErrBundleAlreadySigned)
+	}
+
+	integrityBlock := generateEmptyIntegrityBlock()
+	webBundleHash := sha512.Sum512(bundle)
+
+	for i, signer := range signers {
+		integritySignature, err := SignIntegrityBlock(integrityBlock, signer, webBundleHash[:])
+		if err != nil {
+			return nil, fmt.Errorf("integrityblock: signers[%d]: %v", i, err)
+		}
+		if err := AppendSignature(integrityBlock, integritySignature); err != nil {
+			return nil, err
+		}
+	}
+
+	var signedBundle bytes.Buffer
+	if _, err := WriteSignedBundle(&signedBundle, integrityBlock, bytes.NewReader(bundle)); err != nil {
+		return nil, err
+	}
+	return signedBundle.Bytes(), nil
+}
+
+// ReSignBundle adds a new signature by the given signer on top of the signature stack of the already signed web
+// bundle, e.g. for a second authority countersigning it, and returns the new signed web bundle. The existing
+// signatures are kept intact and in order, and the new signature covers the integrity block containing them.
+// As the existing signatures are only valid as long as the integrity block is re-encoded byte for byte, it
+// fails with `ErrNonCanonicalEncoding` if the existing integrity block is not canonically encoded.
+func ReSignBundle(signedBundle io.ReadSeeker, signer Signer) ([]byte, error) {
+	integrityBlock, integrityBlockLen, err := parseExistingIntegrityBlock(signedBundle)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := signedBundle.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	// Reading the integrity block bytes leaves the reader positioned at the start of the web bundle bytes.
+	integrityBlockBytes := make([]byte, integrityBlockLen)
+	if _, err := io.ReadFull(signedBundle, integrityBlockBytes); err != nil {
+		return nil, err
+	}
+	if err := checkCanonicalEncoding(integrityBlock, integrityBlockBytes); err != nil {
+		return nil, err
+	}
+
+	webBundleHash, err := ComputeWebBundleSha512Stream(signedBundle)
+	if err != nil {
+		return nil, err
+	}
+
+	integritySignature, err := SignIntegrityBlock(integrityBlock, signer, webBundleHash)
+	if err != nil {
+		return nil, err
+	}
+	if err := AppendSignature(integrityBlock, integritySignature); err != nil {
+		return nil, err
+	}
+
+	if _, err := signedBundle.Seek(integrityBlockLen, io.SeekStart); err != nil {
+		return nil, err
+	}
+	var reSignedBundle bytes.Buffer
+	if _, err := WriteSignedBundle(&reSignedBundle, integrityBlock, signedBundle); err != nil {
+		return nil, err
+	}
+	return reSignedBundle.Bytes(), nil
+}
+
+// DryRunSignBundleFile signs the unsigned web bundle at `inputPath` with the given signer like `SignBundleFile`,
+// but instead of writing the signed web bundle, it returns a report of the projected integrity block, the Web
+// Bundle ID and the size of the signed web bundle. The complete signed payload is constructed and signed, so
+// the reported sizes are accurate.
+func DryRunSignBundleFile(inputPath string, signer Signer) (*SigningReport, error) {
+	bundleFile, err := os.Open(inputPath)
+	if err != nil {
+		return nil, err
+	}
+	defer bundleFile.Close()
+
+	fileStats, err := bundleFile.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	integrityBlock, offset, err := ObtainIntegrityBlockFrom(bundleFile, fileStats.Size(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	webBundleHash, err := ComputeWebBundleSha512(bundleFile, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	integritySignature, err := SignIntegrityBlock(integrityBlock, signer, webBundleHash)
+	if err != nil {
+		return nil, err
+	}
+	if err := AppendSignature(integrityBlock, integritySignature); err != nil {
+		return nil, err
+	}
+
+	integrityBlockBytes, err := integrityBlock.ValidatedCborBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	webBundleId, err := webbundleid.NewWebBundleId(signer.PublicKey())
+	if err != nil {
+		return nil, err
+	}
+
+	return &SigningReport{
+		IntegrityBlock:   integrityBlockBytes,
+		WebBundleId:      webBundleId,
+		SignedBundleSize: int64(len(integrityBlockBytes)) + fileStats.Size() - offset,
+	}, nil
+}
+
+// signAndWriteBundle signs the unsigned web bundle of `size` bytes read from `bundleFile` with the given signer
+// and writes the signed web bundle into `w`.
+func signAndWriteBundle(bundleFile *os.File, size int64, w io.Writer, signer Signer) error {
+	integrityBlock, offset, err := ObtainIntegrityBlockFrom(bundleFile, size, nil)
+	if err != nil {
+		return err
+	}
+
+	webBundleHash, err := ComputeWebBundleSha512(bundleFile, offset)
+	if err != nil {
+		return err
+	}
+
+	integritySignature, err := SignIntegrityBlock(integrityBlock, signer, webBundleHash)
+	if err != nil {
+		return err
+	}
+	if err := AppendSignature(integrityBlock, integritySignature); err != nil {
+		return err
+	}
+
+	// Move the file pointer to the start of the web bundle bytes.
+	if _, err := bundleFile.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	_, err = WriteSignedBundle(w, integrityBlock, bundleFile)
+	return err
+}
@@ -0,0 +1,534 @@
+package integrityblock
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/WICG/webpackage/go/integrityblock/webbundleid"
+)
+
+func TestSignBundleFile(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal("Failed to generate test keys")
+	}
+	signer := NewParsedEd25519KeySigningStrategy(priv)
+	outputPath := filepath.Join(t.TempDir(), "signed.wbn")
+
+	if err := SignBundleFile("./testfile.wbn", outputPath, signer); err != nil {
+		t.Fatalf("SignBundleFile. err: %v", err)
+	}
+
+	signedBundleFile, err := os.Open(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer signedBundleFile.Close()
+
+	integrityBlock, err := ParseIntegrityBlock(signedBundleFile)
+	if err != nil {
+		t.Fatalf("ParseIntegrityBlock. err: %v", err)
+	}
+	offset, err := IntegrityBlockLength(signedBundleFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	webBundleHash, err := ComputeWebBundleSha512(signedBundleFile, offset)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := VerifyIntegrityBlock(integrityBlock, webBundleHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid, invalid := result.ValidSignatures(), result.InvalidSignatures()
+	if len(valid) != 1 || len(invalid) != 0 {
+		t.Errorf("integrityblock: got %d valid and %d invalid signatures, want 1 valid", len(valid), len(invalid))
+	}
+	if !bytes.Equal(valid[0].SignatureAttributes[Ed25519publicKeyAttributeName], signer.PublicKey()) {
+		t.Error("Signed web bundle should be signed by the given signer.")
+	}
+}
+
+func TestSignBundleFileIntoInputFile(t *testing.T) {
+	webBundleBytes, err := os.ReadFile("./testfile.wbn")
+	if err != nil {
+		t.Fatal("Failed to read the test file")
+	}
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal("Failed to generate test keys")
+	}
+	signer := NewParsedEd25519KeySigningStrategy(priv)
+
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "unsigned.wbn")
+	if err := os.WriteFile(inputPath, webBundleBytes, 0644); err != nil {
+		t.Fatal(err)
+	}
+	symlinkPath := filepath.Join(dir, "link.wbn")
+	if err := os.Symlink(inputPath, symlinkPath); err != nil {
+		t.Skipf("Cannot create a symlink: %v", err)
+	}
+
+	for _, outputPath := range []string{symlinkPath, dir + string(filepath.Separator) + "." + string(filepath.Separator) + "unsigned.wbn"} {
+		if err := SignBundleFile(inputPath, outputPath, signer); err == nil {
+			t.Errorf("SignBundleFile into %s should fail, as it is the input file.", outputPath)
+		}
+		got, err := os.ReadFile(inputPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, webBundleBytes) {
+			t.Errorf("integrityblock: Signing into %s modified the input file.", outputPath)
+		}
+	}
+}
+
+func TestVerifyBundleFile(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal("Failed to generate test keys")
+	}
+	signedBundlePath := filepath.Join(t.TempDir(), "signed.wbn")
+	if err := SignBundleFile("./testfile.wbn", signedBundlePath, NewParsedEd25519KeySigningStrategy(priv)); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := VerifyBundleFile(signedBundlePath, nil)
+	if err != nil {
+		t.Fatalf("VerifyBundleFile. err: %v", err)
+	}
+	if !result.Valid || len(result.Signatures) != 1 || !bytes.Equal(result.Signatures[0].PublicKey, pub) {
+		t.Errorf("integrityblock: got result: %+v", result)
+	}
+
+	if _, err := VerifyBundleFile(signedBundlePath, []ed25519.PublicKey{pub}); err != nil {
+		t.Errorf("VerifyBundleFile with an allowed signer. err: %v", err)
+	}
+
+	otherPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal("Failed to generate test keys")
+	}
+	result, err = VerifyBundleFile(signedBundlePath, []ed25519.PublicKey{otherPub})
+	if !errors.Is(err, ErrSignerNotAllowed) {
+		t.Errorf("integrityblock: got err: %v\nwant: %v", err, ErrSignerNotAllowed)
+	}
+	if result == nil || !result.Valid {
+		t.Error("VerifyBundleFile should return the result along with ErrSignerNotAllowed.")
+	}
+
+	// An invalid signature fails the allowlist check even if another signature is from an allowed signer.
+	_, otherPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal("Failed to generate test keys")
+	}
+	signedBundle, err := os.ReadFile(signedBundlePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reSignedBundle, err := ReSignBundle(bytes.NewReader(signedBundle), NewParsedEd25519KeySigningStrategy(otherPriv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	integrityBlockBytes, payload, err := SplitSignedBundle(bytes.NewReader(reSignedBundle))
+	if err != nil {
+		t.Fatal(err)
+	}
+	integrityBlock, err := ParseIntegrityBlock(bytes.NewReader(integrityBlockBytes))
+	if err != nil {
+		t.Fatal(err)
+	}
+	integrityBlock.SignatureStack[0].Signature[0] ^= 0xff
+	tamperedBundlePath := filepath.Join(t.TempDir(), "tampered.wbn")
+	var tamperedBundle bytes.Buffer
+	if _, err := WriteSignedBundle(&tamperedBundle, integrityBlock, payload); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(tamperedBundlePath, tamperedBundle.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyBundleFile(tamperedBundlePath, []ed25519.PublicKey{pub}); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("integrityblock: got err: %v\nwant: %v", err, ErrInvalidSignature)
+	}
+	webBundleBytes, err := os.ReadFile("./testfile.wbn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyAgainstAllowlist(integrityBlock, sha512Helper(webBundleBytes), []ed25519.PublicKey{pub}); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("integrityblock: got err: %v\nwant: %v", err, ErrInvalidSignature)
+	}
+
+	if _, err := VerifyBundleFile("./testfile.wbn", nil); !errors.Is(err, ErrBundleNotSigned) {
+		t.Errorf("integrityblock: got err: %v\nwant: %v", err, ErrBundleNotSigned)
+	}
+	if _, err := VerifyBundleFile(filepath.Join(t.TempDir(), "missing.wbn"), nil); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("integrityblock: got err: %v\nwant: %v", err, os.ErrNotExist)
+	}
+}
+
+func TestVerifySignedBundleFile(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal("Failed to generate test keys")
+	}
+	signedBundlePath := filepath.Join(t.TempDir(), "signed.wbn")
+	if err := SignBundleFile("./testfile.wbn", signedBundlePath, NewParsedEd25519KeySigningStrategy(priv)); err != nil {
+		t.Fatal(err)
+	}
+
+	result, validSigners, err := VerifySignedBundleFile(signedBundlePath)
+	if err != nil {
+		t.Fatalf("VerifySignedBundleFile. err: %v", err)
+	}
+	if !result.Valid {
+		t.Errorf("integrityblock: got result: %+v", result)
+	}
+	if len(validSigners) != 1 || !pub.Equal(validSigners[0]) {
+		t.Errorf("integrityblock: got valid signers: %x\nwant: [%x]", validSigners, pub)
+	}
+
+	if _, _, err := VerifySignedBundleFile("./testfile.wbn"); !errors.Is(err, ErrBundleNotSigned) {
+		t.Errorf("integrityblock: got err: %v\nwant: %v", err, ErrBundleNotSigned)
+	}
+}
+
+func TestSignBundleFileWithAlreadySignedBundle(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal("Failed to generate test keys")
+	}
+	signer := NewParsedEd25519KeySigningStrategy(priv)
+	dir := t.TempDir()
+	signedPath := filepath.Join(dir, "signed.wbn")
+	resignedPath := filepath.Join(dir, "resigned.wbn")
+
+	if err := SignBundleFile("./testfile.wbn", signedPath, signer); err != nil {
+		t.Fatal(err)
+	}
+	if err := SignBundleFile(signedPath, resignedPath, signer); !errors.Is(err, ErrBundleAlreadySigned) {
+		t.Errorf("integrityblock: got err: %v\nwant: %v", err, ErrBundleAlreadySigned)
+	}
+	if _, err := os.Stat(resignedPath); !os.IsNotExist(err) {
+		t.Error("No output file should be created when signing fails.")
+	}
+}
+
+func TestSignBundleFileCleansUpOnFailure(t *testing.T) {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal("Failed to generate test keys")
+	}
+	outputPath := filepath.Join(t.TempDir(), "signed.wbn")
+
+	if err := SignBundleFile("./testfile.wbn", outputPath, failingSignerHelper{pub}); err == nil {
+		t.Error("SignBundleFile should fail when the signer fails.")
+	}
+	if _, err := os.Stat(outputPath); !os.IsNotExist(err) {
+		t.Error("No output file should be left behind when signing fails.")
+	}
+}
+
+func TestSignBundleFileInPlace(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal("Failed to generate test keys")
+	}
+	signer := NewParsedEd25519KeySigningStrategy(priv)
+
+	webBundleBytes, err := os.ReadFile("./testfile.wbn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "bundle.wbn")
+	if err := os.WriteFile(path, webBundleBytes, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SignBundleFileInPlace(path, signer); err != nil {
+		t.Fatalf("SignBundleFileInPlace. err: %v", err)
+	}
+
+	signedBundleBytes, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasSuffix(signedBundleBytes, webBundleBytes) || len(signedBundleBytes) == len(webBundleBytes) {
+		t.Error("Signed web bundle should consist of an integrity block followed by the original web bundle.")
+	}
+
+	// Signing again must fail and leave the signed web bundle untouched.
+	if err := SignBundleFileInPlace(path, signer); !errors.Is(err, ErrBundleAlreadySigned) {
+		t.Errorf("integrityblock: got err: %v\nwant: %v", err, ErrBundleAlreadySigned)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, signedBundleBytes) {
+		t.Error("Failed signing should not modify the web bundle.")
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("integrityblock: got %d files in the directory, want no temporary files left behind", len(entries))
+	}
+}
+
+func TestDryRunSignBundleFile(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal("Failed to generate test keys")
+	}
+	signer := NewParsedEd25519KeySigningStrategy(priv)
+	dir := t.TempDir()
+	outputPath := filepath.Join(dir, "signed.wbn")
+
+	report, err := DryRunSignBundleFile("./testfile.wbn", signer)
+	if err != nil {
+		t.Fatalf("DryRunSignBundleFile. err: %v", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("integrityblock: got %d files after dry run, want 0", len(entries))
+	}
+
+	if err := SignBundleFile("./testfile.wbn", outputPath, signer); err != nil {
+		t.Fatal(err)
+	}
+	signedBundle, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.SignedBundleSize != int64(len(signedBundle)) {
+		t.Errorf("integrityblock: got signed bundle size: %d\nwant: %d", report.SignedBundleSize, len(signedBundle))
+	}
+	// Ed25519 signatures are deterministic, so the projected integrity block must match the written one.
+	if !bytes.HasPrefix(signedBundle, report.IntegrityBlock) {
+		t.Error("Signed web bundle should start with the projected integrity block.")
+	}
+	if want := webbundleid.GetWebBundleId(signer.PublicKey()); report.WebBundleId.String() != want {
+		t.Errorf("integrityblock: got web bundle id: %s\nwant: %s", report.WebBundleId, want)
+	}
+}
+
+func TestSignBundleBytes(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal("Failed to generate test keys")
+	}
+	signer := NewParsedEd25519KeySigningStrategy(priv)
+	webBundleBytes, err := os.ReadFile("./testfile.wbn")
+	if err != nil {
+		t.Fatal("Failed to read the test file")
+	}
+
+	got, err := SignBundleBytes(webBundleBytes, signer)
+	if err != nil {
+		t.Fatalf("SignBundleBytes. err: %v", err)
+	}
+
+	// Signing in memory must produce the same signed web bundle as signing the file.
+	outputPath := filepath.Join(t.TempDir(), "signed.wbn")
+	if err := SignBundleFile("./testfile.wbn", outputPath, signer); err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Error("SignBundleBytes should produce the same signed web bundle as SignBundleFile.")
+	}
+
+	if _, err := SignBundleBytes(got, signer); !errors.Is(err, ErrBundleAlreadySigned) {
+		t.Errorf("integrityblock: got err: %v\nwant: %v", err, ErrBundleAlreadySigned)
+	}
+}
+
+func TestReSignBundle(t *testing.T) {
+	_, firstPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal("Failed to generate test keys")
+	}
+	_, secondPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal("Failed to generate test keys")
+	}
+	firstSigner := NewParsedEd25519KeySigningStrategy(firstPriv)
+	secondSigner := NewParsedEd25519KeySigningStrategy(secondPriv)
+	webBundleBytes, err := os.ReadFile("./testfile.wbn")
+	if err != nil {
+		t.Fatal("Failed to read the test file")
+	}
+
+	signedBundle, err := SignBundleBytes(webBundleBytes, firstSigner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	originalIntegrityBlock, err := ParseIntegrityBlock(bytes.NewReader(signedBundle))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reSignedBundle, err := ReSignBundle(bytes.NewReader(signedBundle), secondSigner)
+	if err != nil {
+		t.Fatalf("ReSignBundle. err: %v", err)
+	}
+
+	integrityBlockBytes, payload, err := SplitSignedBundle(bytes.NewReader(reSignedBundle))
+	if err != nil {
+		t.Fatal(err)
+	}
+	integrityBlock, err := ParseIntegrityBlock(bytes.NewReader(integrityBlockBytes))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotPayload, err := io.ReadAll(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(gotPayload, webBundleBytes) {
+		t.Error("Re-signed web bundle should contain the original web bundle bytes.")
+	}
+
+	if len(integrityBlock.SignatureStack) != 2 {
+		t.Fatalf("integrityblock: got %d signatures, want 2", len(integrityBlock.SignatureStack))
+	}
+	// The newest signature is the first one, and the original signature is kept intact after it.
+	if !bytes.Equal(integrityBlock.SignatureStack[0].SignatureAttributes[Ed25519publicKeyAttributeName], secondSigner.PublicKey()) {
+		t.Error("signatureStack[0] should be signed by the second signer.")
+	}
+	if !bytes.Equal(integrityBlock.SignatureStack[1].Signature, originalIntegrityBlock.SignatureStack[0].Signature) {
+		t.Error("signatureStack[1] should be the original signature.")
+	}
+
+	result, err := VerifyIntegrityBlock(integrityBlock, sha512Helper(webBundleBytes))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Valid {
+		t.Errorf("integrityblock: got result: %+v, want valid", result.Signatures)
+	}
+
+	if _, err := ReSignBundle(bytes.NewReader(webBundleBytes), secondSigner); !errors.Is(err, ErrBundleNotSigned) {
+		t.Errorf("integrityblock: got err: %v\nwant: %v", err, ErrBundleNotSigned)
+	}
+
+	// The integrity block array header with a non-minimally encoded length of 3.
+	nonCanonicalBundle := append([]byte{0x98, 0x03}, signedBundle[1:]...)
+	if _, err := ReSignBundle(bytes.NewReader(nonCanonicalBundle), secondSigner); !errors.Is(err, ErrNonCanonicalEncoding) {
+		t.Errorf("integrityblock: got err: %v\nwant: %v", err, ErrNonCanonicalEncoding)
+	}
+}
+
+func TestSignWithKeyring(t *testing.T) {
+	webBundleBytes, err := os.ReadFile("./testfile.wbn")
+	if err != nil {
+		t.Fatal("Failed to read the test file")
+	}
+	var signers []Signer
+	for i := 0; i < 3; i++ {
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal("Failed to generate test keys")
+		}
+		signers = append(signers, NewParsedEd25519KeySigningStrategy(priv))
+	}
+
+	signedBundle, err := SignWithKeyring(webBundleBytes, signers)
+	if err != nil {
+		t.Fatalf("SignWithKeyring. err: %v", err)
+	}
+
+	integrityBlock, err := ParseIntegrityBlock(bytes.NewReader(signedBundle))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(integrityBlock.SignatureStack) != len(signers) {
+		t.Fatalf("integrityblock: got %d signatures, want %d", len(integrityBlock.SignatureStack), len(signers))
+	}
+	// The last signer's signature is the newest one and therefore first on the stack.
+	for i, integritySignature := range integrityBlock.SignatureStack {
+		want := signers[len(signers)-1-i].PublicKey()
+		if !bytes.Equal(integritySignature.SignatureAttributes[Ed25519publicKeyAttributeName], want) {
+			t.Errorf("integrityblock: signatureStack[%d] is not signed by signers[%d]", i, len(signers)-1-i)
+		}
+	}
+
+	result, err := VerifyIntegrityBlock(integrityBlock, sha512Helper(webBundleBytes))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Valid {
+		t.Errorf("integrityblock: got result: %+v, want valid", result.Signatures)
+	}
+
+	if _, err := SignWithKeyring(webBundleBytes, nil); err == nil {
+		t.Error("SignWithKeyring should fail without signers.")
+	}
+}
+
+// wbnSignTestKey returns the Ed25519 key whose seed is the bytes 0, 1, ..., 31, which ./wbn-sign-signed.wbn is
+// signed with.
+func wbnSignTestKey() ed25519.PrivateKey {
+	seed := make([]byte, ed25519.SeedSize)
+	for i := range seed {
+		seed[i] = byte(i)
+	}
+	return ed25519.NewKeyFromSeed(seed)
+}
+
+// ./wbn-sign-signed.wbn is js/sign/tests/testdata/unsigned.wbn signed by the `IntegrityBlockSigner` of the
+// wbn-sign tool in js/sign, run on Node.js with `wbnSignTestKey`.
+func TestVerifyBundleSignedByWbnSign(t *testing.T) {
+	privateKey := wbnSignTestKey()
+
+	result, signers, err := VerifySignedBundleFile("./wbn-sign-signed.wbn")
+	if err != nil {
+		t.Fatalf("VerifySignedBundleFile. err: %v", err)
+	}
+	if !result.Valid {
+		t.Error("Web bundle signed by wbn-sign should have only valid signatures.")
+	}
+	if len(signers) != 1 || !signers[0].Equal(privateKey.Public()) {
+		t.Errorf("integrityblock: got signers: %x\nwant: %x", signers, privateKey.Public())
+	}
+}
+
+func TestSignBundleFileMatchesWbnSign(t *testing.T) {
+	outputPath := filepath.Join(t.TempDir(), "signed.wbn")
+	if err := SignBundleFile("../../js/sign/tests/testdata/unsigned.wbn", outputPath, NewParsedEd25519KeySigningStrategy(wbnSignTestKey())); err != nil {
+		t.Fatalf("SignBundleFile. err: %v", err)
+	}
+
+	got, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile("./wbn-sign-signed.wbn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Ed25519 signatures are deterministic, so both signers must produce exactly the same signed web bundle.
+	if !bytes.Equal(got, want) {
+		t.Error("Signed web bundle should be identical to the one signed by wbn-sign.")
+	}
+}
@@ -1,6 +1,7 @@
 package integrityblock
 
 import (
+	"context"
 	"crypto/ed25519"
 )
 
@@ -11,3 +12,35 @@ type ISigningStrategy interface {
 	// TODO(sonkkeli): Implement once we have security approval.
 	// IsDevSigning() bool
 }
+
+// Signer is the minimal interface needed for creating Ed25519 integrity signatures with `SignIntegrityBlock`.
+// It allows the private key to live outside of the process, e.g. in a cloud KMS.
+type Signer interface {
+	Sign(payload []byte) ([]byte, error)
+	PublicKey() ed25519.PublicKey
+}
+
+// ContextSigner is like `Signer`, but signing gets a context, so that signers making network calls, e.g. to a
+// cloud KMS, can be cancelled and respect deadlines. It is used by `SignIntegrityBlockContext`.
+type ContextSigner interface {
+	Sign(ctx context.Context, payload []byte) ([]byte, error)
+	PublicKey() ed25519.PublicKey
+}
+
+// NewContextSigner adapts the given `Signer`, e.g. the in-memory `ParsedEd25519KeySigningStrategy`, to a
+// `ContextSigner` which ignores the context.
+func NewContextSigner(signer Signer) ContextSigner {
+	return contextIgnoringSigner{signer}
+}
+
+type contextIgnoringSigner struct {
+	signer Signer
+}
+
+func (cis contextIgnoringSigner) Sign(_ context.Context, payload []byte) ([]byte, error) {
+	return cis.signer.Sign(payload)
+}
+
+func (cis contextIgnoringSigner) PublicKey() ed25519.PublicKey {
+	return cis.signer.PublicKey()
+}
@@ -0,0 +1,35 @@
+package integrityblock
+
+import (
+	"crypto/sha512"
+	"hash"
+	"io"
+)
+
+// TeeHashReader wraps an io.Reader and computes the SHA-512 hash over everything read through it, so that
+// the web bundle can be copied and hashed in a single pass.
+type TeeHashReader struct {
+	r io.Reader
+	h hash.Hash
+}
+
+// NewTeeHashReader returns a TeeHashReader reading from `r`.
+func NewTeeHashReader(r io.Reader) *TeeHashReader {
+	return &TeeHashReader{r: r, h: sha512.New()}
+}
+
+// Read implements io.Reader and adds the read bytes to the running hash.
+func (thr *TeeHashReader) Read(p []byte) (int, error) {
+	n, err := thr.r.Read(p)
+	if n > 0 {
+		// hash.Hash never returns an error.
+		thr.h.Write(p[:n])
+	}
+	return n, err
+}
+
+// Sum returns the SHA-512 hash of the bytes read so far. Once the reader is drained, it is the same as
+// the one `ComputeWebBundleSha512Stream` would return for the same bytes.
+func (thr *TeeHashReader) Sum() []byte {
+	return thr.h.Sum(nil)
+}
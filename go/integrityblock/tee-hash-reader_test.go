@@ -0,0 +1,27 @@
+package integrityblock
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestTeeHashReader(t *testing.T) {
+	webBundleBytes, err := os.ReadFile("./testfile.wbn")
+	if err != nil {
+		t.Fatal("Failed to read the test file")
+	}
+
+	var copied bytes.Buffer
+	teeHashReader := NewTeeHashReader(bytes.NewReader(webBundleBytes))
+	if _, err := copied.ReadFrom(teeHashReader); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(copied.Bytes(), webBundleBytes) {
+		t.Error("TeeHashReader should pass the read bytes through unchanged.")
+	}
+	if got, want := teeHashReader.Sum(), sha512Helper(webBundleBytes); !bytes.Equal(got, want) {
+		t.Errorf("integrityblock: got: %x\nwant: %x", got, want)
+	}
+}
@@ -0,0 +1,66 @@
+package integrityblock
+
+import (
+	"crypto/sha512"
+	"crypto/subtle"
+	"hash"
+	"io"
+)
+
+// VerifyingReader wraps the reader of the web bundle bytes and checks their SHA-512 hash against the expected
+// hash while they are read, so that a streaming consumer can abort on tampering without buffering the web
+// bundle. The final Read returns `ErrHashMismatch` instead of io.EOF if the hash doesn't match.
+type VerifyingReader struct {
+	r        io.Reader
+	h        hash.Hash
+	expected []byte
+	err      error
+}
+
+// NewVerifyingReader returns a VerifyingReader reading from `r`, which is expected to be positioned at the
+// start of the web bundle bytes, and comparing their hash against `expectedHash`.
+func NewVerifyingReader(r io.Reader, expectedHash []byte) *VerifyingReader {
+	return &VerifyingReader{r: r, h: sha512.New(), expected: expectedHash}
+}
+
+// Read implements io.Reader. Once the wrapped reader is drained, it returns io.EOF if the hash of all the
+// bytes read matches the expected hash and `ErrHashMismatch` otherwise. Errors are sticky.
+func (vr *VerifyingReader) Read(p []byte) (int, error) {
+	if vr.err != nil {
+		return 0, vr.err
+	}
+
+	n, err := vr.r.Read(p)
+	if n > 0 {
+		// hash.Hash never returns an error.
+		vr.h.Write(p[:n])
+	}
+	if err == io.EOF && !vr.hashMatches() {
+		err = ErrHashMismatch
+	}
+	if err != nil {
+		vr.err = err
+	}
+	return n, err
+}
+
+// Close checks the hash of the bytes read so far and closes the wrapped reader if it is an io.Closer. It
+// returns `ErrHashMismatch` if the hash doesn't match, which is also the case if the consumer stops reading
+// before the end of the web bundle.
+func (vr *VerifyingReader) Close() error {
+	var verifyErr error
+	if !vr.hashMatches() {
+		verifyErr = ErrHashMismatch
+	}
+	if closer, ok := vr.r.(io.Closer); ok {
+		if err := closer.Close(); err != nil && verifyErr == nil {
+			return err
+		}
+	}
+	return verifyErr
+}
+
+// hashMatches tells whether the hash of the bytes read so far matches the expected hash.
+func (vr *VerifyingReader) hashMatches() bool {
+	return subtle.ConstantTimeCompare(vr.h.Sum(nil), vr.expected) == 1
+}
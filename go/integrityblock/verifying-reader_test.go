@@ -0,0 +1,50 @@
+package integrityblock
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"testing"
+)
+
+func TestVerifyingReader(t *testing.T) {
+	webBundleBytes, err := os.ReadFile("./testfile.wbn")
+	if err != nil {
+		t.Fatal("Failed to read the test file")
+	}
+	webBundleHash := sha512Helper(webBundleBytes)
+
+	verifyingReader := NewVerifyingReader(bytes.NewReader(webBundleBytes), webBundleHash)
+	copied, err := io.ReadAll(verifyingReader)
+	if err != nil {
+		t.Fatalf("VerifyingReader. err: %v", err)
+	}
+	if !bytes.Equal(copied, webBundleBytes) {
+		t.Error("VerifyingReader should pass the read bytes through unchanged.")
+	}
+	if err := verifyingReader.Close(); err != nil {
+		t.Errorf("Close. err: %v", err)
+	}
+
+	tampered := bytes.Clone(webBundleBytes)
+	tampered[len(tampered)/2] ^= 0x01
+	verifyingReader = NewVerifyingReader(bytes.NewReader(tampered), webBundleHash)
+	if _, err := io.ReadAll(verifyingReader); !errors.Is(err, ErrHashMismatch) {
+		t.Errorf("integrityblock: got err: %v\nwant: %v", err, ErrHashMismatch)
+	}
+	if _, err := verifyingReader.Read(make([]byte, 1)); !errors.Is(err, ErrHashMismatch) {
+		t.Errorf("integrityblock: got err: %v after the mismatch, want: %v", err, ErrHashMismatch)
+	}
+}
+
+func TestVerifyingReaderClosedEarly(t *testing.T) {
+	webBundleBytes := []byte("webbundle")
+	verifyingReader := NewVerifyingReader(bytes.NewReader(webBundleBytes), sha512Helper(webBundleBytes))
+	if _, err := verifyingReader.Read(make([]byte, 3)); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyingReader.Close(); !errors.Is(err, ErrHashMismatch) {
+		t.Errorf("integrityblock: got err: %v\nwant: %v", err, ErrHashMismatch)
+	}
+}
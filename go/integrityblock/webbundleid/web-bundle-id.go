@@ -1,13 +1,23 @@
 package webbundleid
 
 import (
+	"bytes"
 	"crypto/ed25519"
 	"encoding/base32"
+	"errors"
+	"fmt"
 	"strings"
 )
 
 var webBundleIdSuffix = []byte{0x00, 0x01, 0x02}
 
+var (
+	// ErrMalformedWebBundleId is returned by `VerifyKeyMatchesId` if the expected Web Bundle ID cannot be parsed.
+	ErrMalformedWebBundleId = errors.New("webbundleid: Malformed Web Bundle ID.")
+	// ErrWebBundleIdMismatch is returned by `VerifyKeyMatchesId` if the public key derives another Web Bundle ID.
+	ErrWebBundleIdMismatch = errors.New("webbundleid: Public key doesn't match the Web Bundle ID.")
+)
+
 // GetWebBundleId returns a base32-encoded (without padding) ed25519 public key
 // combined with a 3-byte long suffix and transformed to lowercase. More information:
 // https://github.com/WICG/isolated-web-apps/blob/main/Scheme.md#signed-web-bundle-ids
@@ -17,3 +27,97 @@ func GetWebBundleId(ed25519publicKey ed25519.PublicKey) string {
 	// StdEncoding is the standard base32 encoding, as defined in RFC 4648.
 	return strings.ToLower(base32.StdEncoding.EncodeToString(keyWithSuffix))
 }
+
+// WebBundleId is the Web Bundle ID of an Isolated Web App signed with an ed25519 key. It can only be created
+// from a valid public key with `NewWebBundleId` or from a valid encoded ID with `Parse`; the zero value is not
+// a valid Web Bundle ID.
+type WebBundleId struct {
+	ed25519publicKey ed25519.PublicKey
+}
+
+// NewWebBundleId returns the Web Bundle ID of the given ed25519 public key, or an error instead of a
+// meaningless ID if the public key is not exactly 32 bytes long.
+func NewWebBundleId(ed25519publicKey ed25519.PublicKey) (WebBundleId, error) {
+	if len(ed25519publicKey) != ed25519.PublicKeySize {
+		return WebBundleId{}, fmt.Errorf("webbundleid: Ed25519 public key should be %d bytes, got %d bytes.", ed25519.PublicKeySize, len(ed25519publicKey))
+	}
+	return WebBundleId{ed25519publicKey: bytes.Clone(ed25519publicKey)}, nil
+}
+
+// Parse decodes the lowercase base32 encoded Web Bundle ID and checks that it has the ed25519 type suffix.
+func Parse(webBundleId string) (WebBundleId, error) {
+	if webBundleId != strings.ToLower(webBundleId) {
+		return WebBundleId{}, fmt.Errorf("webbundleid: Web Bundle ID %q should be lowercase.", webBundleId)
+	}
+
+	keyWithSuffix, err := base32.StdEncoding.DecodeString(strings.ToUpper(webBundleId))
+	if err != nil {
+		return WebBundleId{}, fmt.Errorf("webbundleid: Web Bundle ID %q is not base32: %v", webBundleId, err)
+	}
+	if len(keyWithSuffix) != ed25519.PublicKeySize+len(webBundleIdSuffix) {
+		return WebBundleId{}, fmt.Errorf("webbundleid: Web Bundle ID should decode to %d bytes, got %d bytes.", ed25519.PublicKeySize+len(webBundleIdSuffix), len(keyWithSuffix))
+	}
+	if suffix := keyWithSuffix[ed25519.PublicKeySize:]; !bytes.Equal(suffix, webBundleIdSuffix) {
+		return WebBundleId{}, fmt.Errorf("webbundleid: Unknown Web Bundle ID type suffix %x.", suffix)
+	}
+
+	return WebBundleId{ed25519publicKey: ed25519.PublicKey(keyWithSuffix[:ed25519.PublicKeySize])}, nil
+}
+
+// ValidateWebBundleId checks that the given string is a well-formed Web Bundle ID, e.g. when given as a command
+// line argument. It returns the same descriptive errors as `Parse`.
+func ValidateWebBundleId(webBundleId string) error {
+	_, err := Parse(webBundleId)
+	return err
+}
+
+// String returns the Web Bundle ID encoded like `GetWebBundleId` does.
+func (id WebBundleId) String() string {
+	if id.ed25519publicKey == nil {
+		return ""
+	}
+	return GetWebBundleId(id.ed25519publicKey)
+}
+
+// PublicKey returns the ed25519 public key from which the Web Bundle ID is derived.
+func (id WebBundleId) PublicKey() ed25519.PublicKey {
+	return bytes.Clone(id.ed25519publicKey)
+}
+
+// Equal tells whether the Web Bundle IDs are the same.
+func (id WebBundleId) Equal(other WebBundleId) bool {
+	return bytes.Equal(id.ed25519publicKey, other.ed25519publicKey)
+}
+
+// isolatedAppScheme is the URL scheme of Isolated Web Apps, whose origins have the Web Bundle ID as the host.
+const isolatedAppScheme = "isolated-app://"
+
+// SignedWebBundleOrigin returns the origin of the Isolated Web App signed with the given ed25519 public key,
+// meaning "isolated-app://" followed by the Web Bundle ID. Like `NewWebBundleId`, it fails if the public key
+// is not exactly 32 bytes long.
+func SignedWebBundleOrigin(ed25519publicKey ed25519.PublicKey) (string, error) {
+	webBundleId, err := NewWebBundleId(ed25519publicKey)
+	if err != nil {
+		return "", err
+	}
+	return isolatedAppScheme + webBundleId.String(), nil
+}
+
+// VerifyKeyMatchesId checks that the Web Bundle ID derived from the given ed25519 public key is `expectedId`,
+// e.g. the ID an Isolated Web App claims in its manifest, to catch web bundles signed by a key not matching the
+// claimed app identity. It fails with `ErrMalformedWebBundleId` if `expectedId` is not a valid Web Bundle ID
+// and with `ErrWebBundleIdMismatch` if the IDs differ.
+func VerifyKeyMatchesId(ed25519publicKey ed25519.PublicKey, expectedId string) error {
+	expected, err := Parse(expectedId)
+	if err != nil {
+		return fmt.Errorf("%w %v", ErrMalformedWebBundleId, err)
+	}
+	actual, err := NewWebBundleId(ed25519publicKey)
+	if err != nil {
+		return err
+	}
+	if !actual.Equal(expected) {
+		return fmt.Errorf("%w Got %s, want %s.", ErrWebBundleIdMismatch, actual, expected)
+	}
+	return nil
+}
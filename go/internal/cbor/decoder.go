@@ -2,8 +2,10 @@ package cbor
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"unicode/utf8"
 )
 
@@ -46,6 +48,10 @@ func (d *Decoder) decodeTypedUint() (Type, uint64, error) {
 		nfollow = 4
 	case 27:
 		nfollow = 8
+	case 28, 29, 30:
+		return t, 0, fmt.Errorf("cbor: Reserved additional information value %d is not well-formed.", ai)
+	case 31:
+		return t, 0, errors.New("cbor: Indefinite length items are not supported.")
 	default:
 		nfollow = 0
 	}
@@ -74,7 +80,7 @@ func (d *Decoder) decodeOfType(expected Type) (uint64, error) {
 		return 0, err
 	}
 	if t != expected {
-		return 0, fmt.Errorf("cbor: Expected type %v, got type %v", expected, t)
+		return 0, fmt.Errorf("cbor: Expected %v, got %v.", expected, t)
 	}
 	return n, nil
 }
@@ -83,12 +89,88 @@ func (d *Decoder) DecodeUint() (uint64, error) {
 	return d.decodeOfType(TypePosInt)
 }
 
+// RemainingLen returns the number of unread bytes of r if it can be found out: from the Len method of e.g.
+// bytes.Reader, from the remaining limit of an io.LimitedReader, or by seeking, e.g. for *os.File.
+func RemainingLen(r io.Reader) (int64, bool) {
+	switch r := r.(type) {
+	case interface{ Len() int }:
+		return int64(r.Len()), true
+	case *io.LimitedReader:
+		if r.N < 0 {
+			return 0, true
+		}
+		// The underlying reader might end before the limit.
+		if remaining, ok := RemainingLen(r.R); ok && remaining < r.N {
+			return remaining, true
+		}
+		return r.N, true
+	case io.Seeker:
+		current, err := r.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return 0, false
+		}
+		end, err := r.Seek(0, io.SeekEnd)
+		if err != nil {
+			return 0, false
+		}
+		if _, err := r.Seek(current, io.SeekStart); err != nil {
+			return 0, false
+		}
+		if end < current {
+			return 0, true
+		}
+		return end - current, true
+	}
+	return 0, false
+}
+
+// checkDeclaredLength fails if the input cannot contain the declared number of items, each being at least
+// `minItemSize` bytes long. It protects against allocating based on lengths which the input cannot satisfy.
+// If the remaining input size is unknown, the byte and text strings are still read in bounded chunks.
+func (d *Decoder) checkDeclaredLength(n uint64, minItemSize uint64) error {
+	if remaining, ok := RemainingLen(d.r); ok && n > uint64(remaining)/minItemSize {
+		return fmt.Errorf("cbor: Declared length %d exceeds the %d remaining input bytes.", n, remaining)
+	}
+	return nil
+}
+
 func (d *Decoder) DecodeArrayHeader() (uint64, error) {
-	return d.decodeOfType(TypeArray)
+	n, err := d.decodeOfType(TypeArray)
+	if err != nil {
+		return 0, err
+	}
+	// Every array item takes at least one byte.
+	if err := d.checkDeclaredLength(n, 1); err != nil {
+		return 0, err
+	}
+	return n, nil
 }
 
 func (d *Decoder) DecodeMapHeader() (uint64, error) {
-	return d.decodeOfType(TypeMap)
+	n, err := d.decodeOfType(TypeMap)
+	if err != nil {
+		return 0, err
+	}
+	// Every map entry takes at least one byte for the key and one for the value.
+	if err := d.checkDeclaredLength(n, 2); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// DecodeMap decodes the map header and then calls decodeEntry for every key-value pair of the map. The
+// callback is expected to decode exactly one key and one value using the given decoder.
+func (d *Decoder) DecodeMap(decodeEntry func(d *Decoder) error) error {
+	n, err := d.DecodeMapHeader()
+	if err != nil {
+		return err
+	}
+	for i := uint64(0); i < n; i++ {
+		if err := decodeEntry(d); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (d *Decoder) decodeBytesOfType(expected Type) ([]byte, error) {
@@ -96,6 +178,13 @@ func (d *Decoder) decodeBytesOfType(expected Type) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
+	if n > math.MaxInt64 {
+		return nil, fmt.Errorf("cbor: Declared length %d is too large.", n)
+	}
+	if err := d.checkDeclaredLength(n, 1); err != nil {
+		return nil, err
+	}
+	// The buffer grows with the bytes actually read instead of being allocated for the declared length.
 	bs := new(bytes.Buffer)
 	if _, err := io.CopyN(bs, d.r, int64(n)); err != nil {
 		return nil, err
@@ -117,3 +206,64 @@ func (d *Decoder) DecodeTextString() (string, error) {
 func (d *Decoder) DecodeByteString() ([]byte, error) {
 	return d.decodeBytesOfType(TypeBytes)
 }
+
+// maxRawItemDepth limits the nesting of the arrays, maps and tags in a data item decoded with
+// `DecodeRawItem`, so that malicious input cannot exhaust the stack.
+const maxRawItemDepth = 64
+
+// DecodeRawItem reads one complete data item, whatever its type, and returns its encoded bytes as they
+// appeared in the input. It is meant for keeping data items which the caller doesn't know how to interpret.
+func (d *Decoder) DecodeRawItem() ([]byte, error) {
+	var raw bytes.Buffer
+	var r io.Reader = io.TeeReader(d.r, &raw)
+	if remaining, ok := RemainingLen(d.r); ok {
+		// Keep validating the declared lengths, which the io.TeeReader alone would hide.
+		r = io.LimitReader(r, remaining)
+	}
+	if err := NewDecoder(r).skipItem(0); err != nil {
+		return nil, err
+	}
+	return raw.Bytes(), nil
+}
+
+// skipItem reads one complete data item, including the items nested in it, and discards it.
+func (d *Decoder) skipItem(depth int) error {
+	if depth > maxRawItemDepth {
+		return fmt.Errorf("cbor: Data item is nested deeper than %d levels.", maxRawItemDepth)
+	}
+	t, n, err := d.decodeTypedUint()
+	if err != nil {
+		return err
+	}
+
+	switch t {
+	case TypeBytes, TypeText:
+		if n > math.MaxInt64 {
+			return fmt.Errorf("cbor: Declared length %d is too large.", n)
+		}
+		if err := d.checkDeclaredLength(n, 1); err != nil {
+			return err
+		}
+		_, err := io.CopyN(io.Discard, d.r, int64(n))
+		return err
+	case TypeArray:
+		for i := uint64(0); i < n; i++ {
+			if err := d.skipItem(depth + 1); err != nil {
+				return err
+			}
+		}
+	case TypeMap:
+		for i := uint64(0); i < n; i++ {
+			// The key and the value.
+			if err := d.skipItem(depth + 1); err != nil {
+				return err
+			}
+			if err := d.skipItem(depth + 1); err != nil {
+				return err
+			}
+		}
+	case TypeTag:
+		return d.skipItem(depth + 1)
+	}
+	return nil
+}
@@ -1,7 +1,13 @@
 package cbor_test
 
 import (
+	"bufio"
 	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
 
 	. "github.com/WICG/webpackage/go/internal/cbor"
@@ -57,3 +63,188 @@ func TestDecodeByteStringNotCrashing(t *testing.T) {
 		t.Error("got success, want error")
 	}
 }
+
+func TestDecodeNotWellFormed(t *testing.T) {
+	var tests = [][]byte{
+		// Reserved additional information values.
+		{0x1c}, {0x3d}, {0x5e},
+		// Indefinite length byte string.
+		{0x5f, 0x41, 0xab, 0xff},
+		// Truncated argument.
+		{0x59, 0x01},
+	}
+
+	for _, in := range tests {
+		e := NewDecoder(bytes.NewReader(in))
+		if _, err := e.DecodeByteString(); err == nil {
+			t.Errorf("%v: got success, want error", in)
+		}
+	}
+}
+
+func TestDecodeTruncatedTextString(t *testing.T) {
+	e := NewDecoder(bytes.NewReader([]byte{0x65, 'h', 'e', 'l'}))
+	if _, err := e.DecodeTextString(); err == nil {
+		t.Error("got success, want error")
+	}
+}
+
+func TestDecodeMap(t *testing.T) {
+	// {"a": h'01', "b": h'0203'}
+	in := []byte{0xa2, 0x61, 'a', 0x41, 0x01, 0x61, 'b', 0x42, 0x02, 0x03}
+	got := map[string][]byte{}
+
+	e := NewDecoder(bytes.NewReader(in))
+	err := e.DecodeMap(func(d *Decoder) error {
+		key, err := d.DecodeTextString()
+		if err != nil {
+			return err
+		}
+		value, err := d.DecodeByteString()
+		if err != nil {
+			return err
+		}
+		got[key] = value
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("DecodeMap. err: %v", err)
+	}
+
+	if len(got) != 2 || !bytes.Equal(got["a"], []byte{0x01}) || !bytes.Equal(got["b"], []byte{0x02, 0x03}) {
+		t.Errorf("%v expected to decode to {a: [1], b: [2 3]}, actual %v", in, got)
+	}
+
+	e = NewDecoder(bytes.NewReader(in[:len(in)-1]))
+	if err := e.DecodeMap(func(d *Decoder) error {
+		if _, err := d.DecodeTextString(); err != nil {
+			return err
+		}
+		_, err := d.DecodeByteString()
+		return err
+	}); err == nil {
+		t.Error("DecodeMap of a truncated map: got success, want error")
+	}
+}
+
+func TestDecodeDeclaredLengthExceedingInput(t *testing.T) {
+	testCases := map[string]struct {
+		in     []byte
+		decode func(d *Decoder) error
+	}{
+		"array of 2^32 items": {
+			in:     []byte{0x9a, 0xff, 0xff, 0xff, 0xff, 0x01},
+			decode: func(d *Decoder) error { _, err := d.DecodeArrayHeader(); return err },
+		},
+		"map of 2 entries with 3 bytes": {
+			in:     []byte{0xa2, 0x01, 0x02, 0x03},
+			decode: func(d *Decoder) error { _, err := d.DecodeMapHeader(); return err },
+		},
+		"byte string of 2^64-1 bytes": {
+			in:     []byte{0x5b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+			decode: func(d *Decoder) error { _, err := d.DecodeByteString(); return err },
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if err := tc.decode(NewDecoder(bytes.NewReader(tc.in))); err == nil {
+				t.Errorf("%v: got success, want error", tc.in)
+			}
+		})
+	}
+
+	// The remaining length is known from seeking in the file, or from the limit of an io.LimitedReader.
+	hugeByteString := []byte{0x5a, 0xff, 0xff, 0xff, 0xff, 0x01}
+	path := filepath.Join(t.TempDir(), "huge.cbor")
+	if err := os.WriteFile(path, hugeByteString, 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := NewDecoder(f).DecodeByteString(); err == nil || !strings.Contains(err.Error(), "Declared length") {
+		t.Errorf("*os.File: got err: %v, want a declared length error", err)
+	}
+	tee := io.TeeReader(bytes.NewReader(hugeByteString), io.Discard)
+	if _, err := NewDecoder(io.LimitReader(tee, int64(len(hugeByteString)))).DecodeByteString(); err == nil || !strings.Contains(err.Error(), "Declared length") {
+		t.Errorf("io.TeeReader: got err: %v, want a declared length error", err)
+	}
+	tee = io.TeeReader(bytes.NewReader(hugeByteString), io.Discard)
+	if _, err := NewDecoder(io.LimitReader(tee, int64(len(hugeByteString)))).DecodeRawItem(); err == nil || !strings.Contains(err.Error(), "Declared length") {
+		t.Errorf("DecodeRawItem: got err: %v, want a declared length error", err)
+	}
+
+	// Without knowing the remaining length, the byte string is read in bounded chunks, so a huge declared
+	// length fails at the end of the input instead of allocating for the declared length.
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	if _, err := NewDecoder(bufio.NewReader(bytes.NewReader(hugeByteString))).DecodeByteString(); err == nil {
+		t.Errorf("bufio.Reader: got success, want error")
+	}
+	runtime.ReadMemStats(&after)
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20 {
+		t.Errorf("bufio.Reader: allocated %d bytes for a byte string of 1 byte", allocated)
+	}
+
+	// Without knowing the remaining length, a huge byte string must still fail instead of decoding as empty.
+	in := []byte{0x5b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+	if _, err := NewDecoder(io.MultiReader(bytes.NewReader(in))).DecodeByteString(); err == nil {
+		t.Errorf("%v: got success, want error", in)
+	}
+}
+
+func TestDecodeUnexpectedType(t *testing.T) {
+	// "abc" as a text string.
+	d := NewDecoder(bytes.NewReader([]byte{0x63, 'a', 'b', 'c'}))
+	_, err := d.DecodeByteString()
+	if err == nil {
+		t.Fatal("DecodeByteString should fail with a text string.")
+	}
+	if want := "cbor: Expected byte string, got text string."; err.Error() != want {
+		t.Errorf("got: %v\nwant: %s", err, want)
+	}
+}
+
+func TestDecodeRawItem(t *testing.T) {
+	items := [][]byte{
+		{0x01},
+		{0x38, 0x63},
+		{0x43, 'a', 'b', 'c'},
+		{0x63, 'a', 'b', 'c'},
+		{0x82, 0x01, 0x81, 0x40},
+		{0xa1, 0x61, 'k', 0xa0},
+		{0xd8, 0x18, 0x41, 0x03},
+		{0xf5},
+	}
+	var input []byte
+	for _, item := range items {
+		input = append(input, item...)
+	}
+
+	d := NewDecoder(bytes.NewReader(input))
+	for _, want := range items {
+		got, err := d.DecodeRawItem()
+		if err != nil {
+			t.Fatalf("DecodeRawItem. err: %v", err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("got: %x\nwant: %x", got, want)
+		}
+	}
+	if _, err := d.DecodeRawItem(); err != io.EOF {
+		t.Errorf("got err: %v\nwant: %v", err, io.EOF)
+	}
+}
+
+func TestDecodeRawItemNotWellFormed(t *testing.T) {
+	deeplyNested := bytes.Repeat([]byte{0x81}, 100)
+	deeplyNested = append(deeplyNested, 0x00)
+
+	for _, input := range [][]byte{{0x43, 'a'}, {0x82, 0x01}, {0xa1, 0x01}, {0x9f}, deeplyNested} {
+		if _, err := NewDecoder(bytes.NewReader(input)).DecodeRawItem(); err == nil {
+			t.Errorf("DecodeRawItem should fail with %x.", input)
+		}
+	}
+}
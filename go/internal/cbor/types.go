@@ -1,6 +1,8 @@
 // Package cbor defines a parser and encoder for a subset of CBOR, RFC7049.
 package cbor
 
+import "fmt"
+
 type Type byte
 
 const (
@@ -14,6 +16,30 @@ const (
 	TypeOther       = 0xe0
 )
 
+// String returns the name of the major type, used in the decoding error messages.
+func (t Type) String() string {
+	switch t {
+	case TypePosInt:
+		return "unsigned integer"
+	case TypeNegInt:
+		return "negative integer"
+	case TypeBytes:
+		return "byte string"
+	case TypeText:
+		return "text string"
+	case TypeArray:
+		return "array"
+	case TypeMap:
+		return "map"
+	case TypeTag:
+		return "tag"
+	case TypeOther:
+		return "simple value or float"
+	default:
+		return fmt.Sprintf("unknown type 0x%02x", byte(t))
+	}
+}
+
 // getMajorType returns the first 3 bits of the first byte representing cbor's major type.
 // https://www.rfc-editor.org/rfc/rfc8949.html#name-major-types
 func getMajorType(b byte) Type {